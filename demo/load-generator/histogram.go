@@ -0,0 +1,69 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBucketBoundsNs are upper bounds (in nanoseconds) for a bucketed
+// latency histogram, roughly doubling from 100µs to 30s - wide enough to
+// cover everything from a same-host replay to a slow, congested reconnect.
+var latencyBucketBoundsNs = buildLatencyBucketBounds()
+
+func buildLatencyBucketBounds() []int64 {
+	const (
+		start = int64(100 * time.Microsecond)
+		end   = int64(30 * time.Second)
+	)
+
+	var bounds []int64
+	for v := start; v < end; v *= 2 {
+		bounds = append(bounds, v)
+	}
+	return append(bounds, end)
+}
+
+// LatencyHistogram is a lock-free bucketed latency histogram. It trades
+// exact percentiles for O(1) recording on the hot path; Percentile()
+// resolves to the bucket boundary rather than interpolating within it.
+type LatencyHistogram struct {
+	buckets []atomic.Int64 // counts per latencyBucketBoundsNs entry, plus one overflow bucket
+	count   atomic.Int64
+}
+
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{buckets: make([]atomic.Int64, len(latencyBucketBoundsNs)+1)}
+}
+
+func (h *LatencyHistogram) Record(latencyNs int64) {
+	idx := sort.Search(len(latencyBucketBoundsNs), func(i int) bool {
+		return latencyBucketBoundsNs[i] >= latencyNs
+	})
+	h.buckets[idx].Add(1)
+	h.count.Add(1)
+}
+
+// Percentile returns the nanosecond upper boundary of the first bucket at
+// or below which at least p fraction of recorded samples fall, e.g.
+// Percentile(0.95) for p95.
+func (h *LatencyHistogram) Percentile(p float64) int64 {
+	total := h.count.Load()
+	if total == 0 {
+		return 0
+	}
+
+	target := int64(math.Ceil(p * float64(total)))
+	var cumulative int64
+	for i := range h.buckets {
+		cumulative += h.buckets[i].Load()
+		if cumulative >= target {
+			if i == len(latencyBucketBoundsNs) {
+				return latencyBucketBoundsNs[len(latencyBucketBoundsNs)-1]
+			}
+			return latencyBucketBoundsNs[i]
+		}
+	}
+	return latencyBucketBoundsNs[len(latencyBucketBoundsNs)-1]
+}
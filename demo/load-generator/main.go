@@ -17,9 +17,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/nats-io/nats.go"
 	"github.com/redis/go-redis/v9"
 )
 
+// chatTokensStreamName must match the JetStream stream the proxy's
+// natsPublisher creates so both sides agree on where conversation subjects live.
+const chatTokensStreamName = "CHAT_TOKENS"
+
 // TokenMessage matches the format used by the bridge and SSE adapter
 type TokenMessage struct {
 	ConversationID string `json:"conversation_id"`
@@ -36,43 +41,15 @@ type Stats struct {
 	ConnectionsOpened atomic.Int64
 	ConnectionsClosed atomic.Int64
 	Errors            atomic.Int64
-	TotalLatencyNs    atomic.Int64
-	MinLatencyNs      atomic.Int64
-	MaxLatencyNs      atomic.Int64
+	Latency           *LatencyHistogram
 }
 
-func (s *Stats) RecordLatency(latencyNs int64) {
-	s.TotalLatencyNs.Add(latencyNs)
-
-	// Update min (compare-and-swap loop)
-	for {
-		current := s.MinLatencyNs.Load()
-		if current != 0 && current <= latencyNs {
-			break
-		}
-		if s.MinLatencyNs.CompareAndSwap(current, latencyNs) {
-			break
-		}
-	}
-
-	// Update max
-	for {
-		current := s.MaxLatencyNs.Load()
-		if current >= latencyNs {
-			break
-		}
-		if s.MaxLatencyNs.CompareAndSwap(current, latencyNs) {
-			break
-		}
-	}
+func NewStats() *Stats {
+	return &Stats{Latency: NewLatencyHistogram()}
 }
 
 func (s *Stats) Print() {
 	received := s.TokensReceived.Load()
-	avgLatency := float64(0)
-	if received > 0 {
-		avgLatency = float64(s.TotalLatencyNs.Load()) / float64(received) / 1e6
-	}
 
 	fmt.Printf("\n=== Load Test Statistics ===\n")
 	fmt.Printf("Tokens Published:    %d\n", s.TokensPublished.Load())
@@ -81,9 +58,11 @@ func (s *Stats) Print() {
 	fmt.Printf("Connections Closed:  %d\n", s.ConnectionsClosed.Load())
 	fmt.Printf("Errors:              %d\n", s.Errors.Load())
 	if received > 0 {
-		fmt.Printf("Avg Latency:         %.2f ms\n", avgLatency)
-		fmt.Printf("Min Latency:         %.2f ms\n", float64(s.MinLatencyNs.Load())/1e6)
-		fmt.Printf("Max Latency:         %.2f ms\n", float64(s.MaxLatencyNs.Load())/1e6)
+		fmt.Printf("Latency p50:         %.2f ms\n", float64(s.Latency.Percentile(0.50))/1e6)
+		fmt.Printf("Latency p90:         %.2f ms\n", float64(s.Latency.Percentile(0.90))/1e6)
+		fmt.Printf("Latency p95:         %.2f ms\n", float64(s.Latency.Percentile(0.95))/1e6)
+		fmt.Printf("Latency p99:         %.2f ms\n", float64(s.Latency.Percentile(0.99))/1e6)
+		fmt.Printf("Latency p99.9:       %.2f ms\n", float64(s.Latency.Percentile(0.999))/1e6)
 	}
 	fmt.Printf("============================\n")
 }
@@ -98,14 +77,24 @@ The SSE adapter converts NATS messages into Server-Sent Events for browser clien
 func main() {
 	// Command line flags
 	mode := flag.String("mode", "both", "Mode: producer, consumer, or both")
-	redisAddr := flag.String("redis", "localhost:6379", "Redis address")
-	sseURL := flag.String("sse", "http://localhost:8080", "SSE adapter base URL")
+	backend := flag.String("backend", "redis", "Publish/subscribe backend: redis or nats")
+	redisMode := flag.String("redis-mode", "standalone", "Redis topology: standalone, sentinel, or cluster")
+	redisAddr := flag.String("redis", "localhost:6379", "Redis address (standalone mode)")
+	redisSentinelAddrs := flag.String("redis-sentinel", "", "Comma-separated Sentinel addresses (sentinel mode)")
+	redisMasterName := flag.String("redis-master-name", "mymaster", "Sentinel master name (sentinel mode)")
+	redisClusterAddrs := flag.String("redis-cluster", "", "Comma-separated cluster node addresses (cluster mode)")
+	natsURL := flag.String("nats", "nats://localhost:4222", "NATS URL (used when -backend=nats)")
+	sseURL := flag.String("sse", "http://localhost:8080", "SSE adapter base URL (used when -backend=redis)")
 	numConversations := flag.Int("conversations", 5, "Number of concurrent conversations")
 	tokensPerConversation := flag.Int("tokens", 50, "Tokens per conversation")
 	tokenDelayMs := flag.Int("token-delay", 50, "Delay between tokens in ms")
 	duration := flag.Duration("duration", 30*time.Second, "Test duration (0 for single run)")
 	flag.Parse()
 
+	if *backend != "redis" && *backend != "nats" {
+		log.Fatalf("Unknown -backend %q (want redis or nats)", *backend)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -118,7 +107,7 @@ func main() {
 		cancel()
 	}()
 
-	stats := &Stats{}
+	stats := NewStats()
 	var wg sync.WaitGroup
 
 	log.Printf("Starting load generator in %s mode", *mode)
@@ -149,13 +138,43 @@ func main() {
 		conversationIDs[i] = fmt.Sprintf("loadtest-%d-%d", time.Now().UnixNano(), i)
 	}
 
+	// Connect to NATS JetStream up front when that's the chosen backend, since
+	// both the producer and consumer goroutines below share the same context.
+	var js nats.JetStreamContext
+	if *backend == "nats" {
+		nc, err := nats.Connect(*natsURL, nats.Name("load-generator"))
+		if err != nil {
+			log.Fatalf("Failed to connect to NATS: %v", err)
+		}
+		defer nc.Close()
+
+		js, err = nc.JetStream()
+		if err != nil {
+			log.Fatalf("Failed to create JetStream context: %v", err)
+		}
+
+		if _, err := js.AddStream(&nats.StreamConfig{
+			Name:     chatTokensStreamName,
+			Subjects: []string{"chat.*.tokens"},
+		}); err != nil && err != nats.ErrStreamNameAlreadyInUse {
+			log.Fatalf("Failed to create JetStream stream: %v", err)
+		}
+		log.Printf("Connected to NATS at %s", *natsURL)
+	}
+
 	// Start consumers first (if enabled)
 	if *mode == "consumer" || *mode == "both" {
+		var subscriber TokenSubscriber
+		if *backend == "nats" {
+			subscriber = newNATSSubscriber(js)
+		} else {
+			subscriber = newSSESubscriber(*sseURL)
+		}
 		for _, convID := range conversationIDs {
 			wg.Add(1)
 			go func(id string) {
 				defer wg.Done()
-				runConsumer(ctx, *sseURL, id, stats)
+				subscriber.Subscribe(ctx, id, stats)
 			}(convID)
 		}
 		// Give consumers time to connect
@@ -164,22 +183,40 @@ func main() {
 
 	// Start producers (if enabled)
 	if *mode == "producer" || *mode == "both" {
-		rdb := redis.NewClient(&redis.Options{
-			Addr: *redisAddr,
-		})
-		defer rdb.Close()
-
-		if err := rdb.Ping(ctx).Err(); err != nil {
-			log.Fatalf("Failed to connect to Redis: %v", err)
-		}
-		log.Printf("Connected to Redis at %s", *redisAddr)
+		if *backend == "nats" {
+			for _, convID := range conversationIDs {
+				wg.Add(1)
+				go func(id string) {
+					defer wg.Done()
+					runNATSProducer(ctx, js, id, *tokensPerConversation, *tokenDelayMs, stats)
+				}(convID)
+			}
+		} else {
+			rdb, err := newRedisClient(redisClientConfig{
+				mode:          *redisMode,
+				addr:          *redisAddr,
+				sentinelAddrs: splitAddrs(*redisSentinelAddrs),
+				masterName:    *redisMasterName,
+				clusterAddrs:  splitAddrs(*redisClusterAddrs),
+			})
+			if err != nil {
+				log.Fatalf("Failed to configure Redis: %v", err)
+			}
+			defer rdb.Close()
 
-		for _, convID := range conversationIDs {
-			wg.Add(1)
-			go func(id string) {
-				defer wg.Done()
-				runProducer(ctx, rdb, id, *tokensPerConversation, *tokenDelayMs, stats)
-			}(convID)
+			if err := rdb.Ping(ctx).Err(); err != nil {
+				log.Fatalf("Failed to connect to Redis: %v", err)
+			}
+			log.Printf("Connected to Redis in %s mode", *redisMode)
+
+			clusterMode := *redisMode == "cluster"
+			for _, convID := range conversationIDs {
+				wg.Add(1)
+				go func(id string) {
+					defer wg.Done()
+					runProducer(ctx, rdb, id, *tokensPerConversation, *tokenDelayMs, clusterMode, stats)
+				}(convID)
+			}
 		}
 	}
 
@@ -201,8 +238,13 @@ func main() {
 }
 
 // runProducer publishes tokens to Redis, simulating LLM output
-func runProducer(ctx context.Context, rdb *redis.Client, conversationID string, numTokens, delayMs int, stats *Stats) {
+func runProducer(ctx context.Context, rdb redis.UniversalClient, conversationID string, numTokens, delayMs int, clusterMode bool, stats *Stats) {
 	channel := fmt.Sprintf("llm:tokens:%s", conversationID)
+	if clusterMode {
+		// Hash-tag the key so the publisher and the bridge's subscriber land
+		// on the same cluster shard regardless of which node they connect to.
+		channel = fmt.Sprintf("llm:tokens:{%s}", conversationID)
+	}
 	log.Printf("[Producer] Starting conversation %s", conversationID)
 
 	for i := 0; i < numTokens; i++ {
@@ -239,6 +281,95 @@ func runProducer(ctx context.Context, rdb *redis.Client, conversationID string,
 	log.Printf("[Producer] Completed conversation %s (%d tokens)", conversationID, numTokens)
 }
 
+// runNATSProducer publishes tokens to a JetStream subject, simulating LLM
+// output the way the proxy's natsPublisher would when PUBLISH_BACKEND=nats.
+func runNATSProducer(ctx context.Context, js nats.JetStreamContext, conversationID string, numTokens, delayMs int, stats *Stats) {
+	subject := fmt.Sprintf("chat.%s.tokens", conversationID)
+	log.Printf("[Producer] Starting conversation %s (nats)", conversationID)
+
+	for i := 0; i < numTokens; i++ {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		token := TokenMessage{
+			ConversationID: conversationID,
+			Token:          sampleText[i%len(sampleText)],
+			Sequence:       int64(i + 1),
+			Done:           i == numTokens-1,
+			Timestamp:      time.Now().UnixNano(),
+		}
+
+		data, _ := json.Marshal(token)
+		if _, err := js.Publish(subject, data); err != nil {
+			log.Printf("[Producer] Error publishing: %v", err)
+			stats.Errors.Add(1)
+			continue
+		}
+
+		stats.TokensPublished.Add(1)
+
+		if delayMs > 0 {
+			// Add some jitter to simulate realistic LLM token timing
+			jitter := rand.Intn(delayMs/2 + 1)
+			time.Sleep(time.Duration(delayMs+jitter) * time.Millisecond)
+		}
+	}
+
+	log.Printf("[Producer] Completed conversation %s (%d tokens)", conversationID, numTokens)
+}
+
+// runNATSConsumer subscribes to a conversation's subject with a durable
+// JetStream consumer, so a crash and resume of the load generator itself
+// would pick back up instead of losing in-flight tokens, and records the
+// same latency/throughput stats the Redis+SSE path does.
+func runNATSConsumer(ctx context.Context, js nats.JetStreamContext, conversationID string, stats *Stats) {
+	subject := fmt.Sprintf("chat.%s.tokens", conversationID)
+	durableName := "loadgen-" + conversationID
+	log.Printf("[Consumer] Subscribing to %s (nats, durable=%s)", subject, durableName)
+
+	done := make(chan struct{})
+	var closeOnce sync.Once
+
+	sub, err := js.Subscribe(subject, func(msg *nats.Msg) {
+		var token TokenMessage
+		if err := json.Unmarshal(msg.Data, &token); err != nil {
+			msg.Ack()
+			return
+		}
+
+		if token.Timestamp > 0 {
+			latency := time.Now().UnixNano() - token.Timestamp
+			stats.Latency.Record(latency)
+		}
+		stats.TokensReceived.Add(1)
+		msg.Ack()
+
+		if token.Done {
+			closeOnce.Do(func() { close(done) })
+		}
+	}, nats.Durable(durableName), nats.ManualAck(), nats.AckExplicit())
+	if err != nil {
+		log.Printf("[Consumer] Error subscribing: %v", err)
+		stats.Errors.Add(1)
+		return
+	}
+	defer sub.Unsubscribe()
+
+	stats.ConnectionsOpened.Add(1)
+	defer stats.ConnectionsClosed.Add(1)
+
+	log.Printf("[Consumer] Connected to %s", conversationID)
+
+	select {
+	case <-ctx.Done():
+	case <-done:
+		log.Printf("[Consumer] Conversation %s completed", conversationID)
+	}
+}
+
 // runConsumer connects to SSE endpoint and receives tokens
 func runConsumer(ctx context.Context, baseURL, conversationID string, stats *Stats) {
 	url := fmt.Sprintf("%s/stream/%s", baseURL, conversationID)
@@ -298,7 +429,7 @@ func runConsumer(ctx context.Context, baseURL, conversationID string, stats *Sta
 			// Calculate latency
 			if token.Timestamp > 0 {
 				latency := time.Now().UnixNano() - token.Timestamp
-				stats.RecordLatency(latency)
+				stats.Latency.Record(latency)
 			}
 
 			stats.TokensReceived.Add(1)
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisClientConfig mirrors the proxy's RedisConfig but is driven by CLI
+// flags instead of env vars, matching this binary's existing convention.
+type redisClientConfig struct {
+	mode          string // standalone, sentinel, or cluster
+	addr          string
+	sentinelAddrs []string
+	masterName    string
+	clusterAddrs  []string
+}
+
+// newRedisClient builds a redis.UniversalClient for the configured topology
+// so the load generator can drive a standalone node, a Sentinel failover
+// group, or a Cluster with the same producer code.
+func newRedisClient(cfg redisClientConfig) (redis.UniversalClient, error) {
+	switch cfg.mode {
+	case "standalone", "":
+		return redis.NewClient(&redis.Options{Addr: cfg.addr}), nil
+
+	case "sentinel":
+		if len(cfg.sentinelAddrs) == 0 {
+			return nil, fmt.Errorf("-redis-sentinel is required for -redis-mode=sentinel")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.masterName,
+			SentinelAddrs: cfg.sentinelAddrs,
+		}), nil
+
+	case "cluster":
+		if len(cfg.clusterAddrs) == 0 {
+			return nil, fmt.Errorf("-redis-cluster is required for -redis-mode=cluster")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{Addrs: cfg.clusterAddrs}), nil
+
+	default:
+		return nil, fmt.Errorf("unknown -redis-mode %q (want standalone, sentinel, or cluster)", cfg.mode)
+	}
+}
+
+func splitAddrs(v string) []string {
+	if v == "" {
+		return nil
+	}
+	var addrs []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			addrs = append(addrs, part)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+// TokenSubscriber receives one conversation's token stream and records
+// latency/throughput into stats, mirroring TokenPublisher's shape in
+// llm-stream-proxy/publisher.go so the same -backend flag swaps both the
+// producer and the consumer side of the test harness. There is no
+// TokenSubscriber in llm-stream-proxy itself: the proxy only ever publishes
+// (streamFromLLM -> publishToken), it never consumes its own tokens back.
+// The actual consumer-side abstraction for that direction is the SSE
+// adapter's MessageBus (see src/sse-adapter/messagebus.go), added in a later
+// request; this interface covers the load generator's own verification
+// consumers instead.
+type TokenSubscriber interface {
+	Subscribe(ctx context.Context, conversationID string, stats *Stats)
+}
+
+// natsSubscriber drains a conversation's subject via a durable JetStream
+// consumer, so a load generator crash and restart resumes instead of
+// dropping in-flight tokens.
+type natsSubscriber struct {
+	js nats.JetStreamContext
+}
+
+func newNATSSubscriber(js nats.JetStreamContext) TokenSubscriber {
+	return &natsSubscriber{js: js}
+}
+
+func (s *natsSubscriber) Subscribe(ctx context.Context, conversationID string, stats *Stats) {
+	runNATSConsumer(ctx, s.js, conversationID, stats)
+}
+
+// sseSubscriber drains a conversation by connecting to the SSE adapter's
+// /stream/:id endpoint, the way a real browser client would against the
+// Redis-backed path.
+type sseSubscriber struct {
+	baseURL string
+}
+
+func newSSESubscriber(baseURL string) TokenSubscriber {
+	return &sseSubscriber{baseURL: baseURL}
+}
+
+func (s *sseSubscriber) Subscribe(ctx context.Context, conversationID string, stats *Stats) {
+	runConsumer(ctx, s.baseURL, conversationID, stats)
+}
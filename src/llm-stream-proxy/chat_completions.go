@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatCompletionChunk is an OpenAI-compatible streaming chunk, as sent by
+// POST /v1/chat/completions with stream: true.
+type ChatCompletionChunk struct {
+	ID      string                      `json:"id"`
+	Object  string                      `json:"object"`
+	Created int64                       `json:"created"`
+	Model   string                      `json:"model"`
+	Choices []ChatCompletionChunkChoice `json:"choices"`
+}
+
+type ChatCompletionChunkChoice struct {
+	Index        int                      `json:"index"`
+	Delta        ChatCompletionChunkDelta `json:"delta"`
+	FinishReason *string                  `json:"finish_reason"`
+}
+
+type ChatCompletionChunkDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// handleChatCompletions implements an OpenAI-compatible /v1/chat/completions
+// endpoint: it streams tokens back to the caller directly as OpenAI-format
+// SSE chunks while simultaneously publishing TokenMessages through the usual
+// fan-out path, so the same request can be consumed as a single-client
+// OpenAI stream and, if another client knows the conversation ID, via the
+// NATS/Redis + SSE adapter path too - without running two deployments.
+func handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Messages) == 0 {
+		http.Error(w, "messages is required", http.StatusBadRequest)
+		return
+	}
+
+	if !req.Stream {
+		http.Error(w, "only stream: true is supported", http.StatusBadRequest)
+		return
+	}
+
+	if req.Model == "" {
+		req.Model = modelName
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conversationID := uuid.New().String()
+	completionID := "chatcmpl-" + conversationID
+	created := time.Now().Unix()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	activeChatsGauge.Inc()
+	defer activeChatsGauge.Dec()
+
+	ctx := r.Context()
+
+	writeChunk := func(content string, finishReason *string) {
+		chunk := ChatCompletionChunk{
+			ID:      completionID,
+			Object:  "chat.completion.chunk",
+			Created: created,
+			Model:   req.Model,
+			Choices: []ChatCompletionChunkChoice{{
+				Delta:        ChatCompletionChunkDelta{Content: content},
+				FinishReason: finishReason,
+			}},
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	relayVLLMStream(ctx, conversationID, req, func(token string, sequence int64, done bool) {
+		publishToken(ctx, conversationID, token, sequence, done)
+
+		if done {
+			finishReason := "stop"
+			writeChunk("", &finishReason)
+			return
+		}
+		writeChunk(token, nil)
+	})
+
+	fmt.Fprintf(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
@@ -5,17 +5,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/redis/go-redis/v9"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // ChatRequest is the request format for the /chat endpoint
@@ -59,35 +57,57 @@ type TokenMessage struct {
 	Timestamp      int64  `json:"timestamp"`
 }
 
+// streamMaxLen bounds each conversation's Redis Stream so replay buffers
+// don't grow unbounded; XADD trims approximately (MAXLEN ~) for performance.
+const streamMaxLen = 1000
+
 var (
-	rdb         *redis.Client
-	vllmURL     string
-	modelName   string
-	activeChats atomic.Int64
+	publisher TokenPublisher
+	vllmURL   string
+	modelName string
 )
 
 func main() {
 	// Configuration
-	redisAddr := getEnv("REDIS_ADDR", "redis.redis-system.svc.cluster.local:6379")
+	natsURL := getEnv("NATS_URL", "nats://localhost:4222")
+	publishBackend := getEnv("PUBLISH_BACKEND", "redis")
 	vllmURL = getEnv("VLLM_URL", "http://llm-inference.llm-system.svc.cluster.local:8000")
 	modelName = getEnv("MODEL_NAME", "mistralai/Mistral-7B-Instruct-v0.3")
 	port := getEnv("PORT", "8080")
 
-	// Connect to Redis
-	rdb = redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
-
 	ctx := context.Background()
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+
+	switch publishBackend {
+	case "redis":
+		redisCfg := loadRedisConfig()
+		rdb, err := newRedisClient(redisCfg)
+		if err != nil {
+			log.Fatalf("Failed to configure Redis: %v", err)
+		}
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			log.Fatalf("Failed to connect to Redis: %v", err)
+		}
+		log.Printf("Connected to Redis in %s mode", redisCfg.Mode)
+		publisher = newRedisPublisher(rdb, redisCfg.Mode == RedisModeCluster)
+
+	case "nats":
+		p, err := newNATSPublisher(natsURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to NATS: %v", err)
+		}
+		log.Printf("Connected to NATS at %s", natsURL)
+		publisher = p
+
+	default:
+		log.Fatalf("Unknown PUBLISH_BACKEND %q (want redis or nats)", publishBackend)
 	}
-	log.Printf("Connected to Redis at %s", redisAddr)
+	defer publisher.Close()
 
 	// HTTP endpoints
 	http.HandleFunc("/chat", handleChat)
+	http.HandleFunc("/v1/chat/completions", handleChatCompletions)
 	http.HandleFunc("/health", handleHealth)
-	http.HandleFunc("/metrics", handleMetrics)
+	http.Handle("/metrics", promhttp.Handler())
 
 	log.Printf("Starting LLM Stream Proxy on port %s", port)
 	log.Printf("vLLM endpoint: %s", vllmURL)
@@ -106,10 +126,6 @@ func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("ok"))
 }
 
-func handleMetrics(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "active_chats %d\n", activeChats.Load())
-}
-
 func handleChat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -142,12 +158,11 @@ func handleChat(w http.ResponseWriter, r *http.Request) {
 }
 
 func streamFromLLM(conversationID, message string) {
-	activeChats.Add(1)
-	defer activeChats.Add(-1)
+	activeChatsGauge.Inc()
+	defer activeChatsGauge.Dec()
 
 	ctx := context.Background()
 
-	// Prepare vLLM request
 	chatReq := ChatCompletionRequest{
 		Model: modelName,
 		Messages: []Message{
@@ -156,12 +171,47 @@ func streamFromLLM(conversationID, message string) {
 		Stream: true,
 	}
 
+	relayVLLMStream(ctx, conversationID, chatReq, func(token string, sequence int64, done bool) {
+		publishToken(ctx, conversationID, token, sequence, done)
+	})
+}
+
+// relayVLLMStream posts chatReq to the vLLM endpoint and invokes onToken for
+// every content delta in its SSE response, followed by one final call with
+// done=true once a finish_reason or [DONE] arrives (or a synthetic [ERROR]
+// token if the request to vLLM itself fails). Shared by the legacy /chat
+// endpoint and the OpenAI-compatible /v1/chat/completions one so both relay
+// tokens the same way. Along the way it records time-to-first-token and
+// inter-token latency, labeled by model, for the /metrics endpoint.
+func relayVLLMStream(ctx context.Context, conversationID string, chatReq ChatCompletionRequest, onToken func(token string, sequence int64, done bool)) {
+	model := chatReq.Model
+	if model == "" {
+		model = modelName
+	}
+
+	start := time.Now()
+	firstToken := true
+	var lastTokenAt time.Time
+
+	emit := func(token string, sequence int64, done bool) {
+		now := time.Now()
+		if firstToken {
+			timeToFirstTokenSeconds.WithLabelValues(model).Observe(now.Sub(start).Seconds())
+			firstToken = false
+		} else if !done {
+			interTokenLatencySeconds.WithLabelValues(model).Observe(now.Sub(lastTokenAt).Seconds())
+		}
+		lastTokenAt = now
+
+		onToken(token, sequence, done)
+	}
+
 	reqBody, _ := json.Marshal(chatReq)
 
-	req, err := http.NewRequest("POST", vllmURL+"/v1/chat/completions", bytes.NewReader(reqBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", vllmURL+"/v1/chat/completions", bytes.NewReader(reqBody))
 	if err != nil {
 		log.Printf("[%s] Failed to create request: %v", conversationID, err)
-		publishToken(ctx, conversationID, "[ERROR]", 1, true)
+		emit("[ERROR]", 1, true)
 		return
 	}
 	req.Header.Set("Content-Type", "application/json")
@@ -169,7 +219,7 @@ func streamFromLLM(conversationID, message string) {
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("[%s] Failed to call vLLM: %v", conversationID, err)
-		publishToken(ctx, conversationID, "[ERROR]", 1, true)
+		emit("[ERROR]", 1, true)
 		return
 	}
 	defer resp.Body.Close()
@@ -177,7 +227,7 @@ func streamFromLLM(conversationID, message string) {
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Printf("[%s] vLLM error: %s - %s", conversationID, resp.Status, string(body))
-		publishToken(ctx, conversationID, "[ERROR]", 1, true)
+		emit("[ERROR]", 1, true)
 		return
 	}
 
@@ -201,8 +251,8 @@ func streamFromLLM(conversationID, message string) {
 
 		data := strings.TrimPrefix(line, "data: ")
 		if data == "[DONE]" {
-			publishToken(ctx, conversationID, "[DONE]", sequence+1, true)
-			break
+			emit("[DONE]", sequence+1, true)
+			return
 		}
 
 		var chunk StreamChunk
@@ -213,10 +263,10 @@ func streamFromLLM(conversationID, message string) {
 		for _, choice := range chunk.Choices {
 			if choice.Delta.Content != "" {
 				sequence++
-				publishToken(ctx, conversationID, choice.Delta.Content, sequence, false)
+				emit(choice.Delta.Content, sequence, false)
 			}
 			if choice.FinishReason != nil {
-				publishToken(ctx, conversationID, "[DONE]", sequence+1, true)
+				emit("[DONE]", sequence+1, true)
 				return
 			}
 		}
@@ -232,10 +282,15 @@ func publishToken(ctx context.Context, conversationID, token string, sequence in
 		Timestamp:      time.Now().UnixNano(),
 	}
 
-	data, _ := json.Marshal(msg)
-	channel := "chat." + conversationID + ".tokens"
-
-	if err := rdb.Publish(ctx, channel, data).Err(); err != nil {
+	if err := publisher.Publish(ctx, msg); err != nil {
 		log.Printf("[%s] Failed to publish token: %v", conversationID, err)
+		publishErrorsTotal.Inc()
+		return
 	}
+	tokensPublishedTotal.Inc()
+}
+
+// streamKey returns the Redis Stream key for a conversation's token log.
+func streamKey(conversationID string) string {
+	return "llm:stream:" + conversationID
 }
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Prometheus metrics for the streaming hot path: one token published or
+// errored per broker write, plus latency histograms labeled by model so
+// slow models don't get averaged away by fast ones.
+var (
+	activeChatsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "active_chats",
+		Help: "Number of conversations currently streaming from vLLM.",
+	})
+
+	tokensPublishedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tokens_published_total",
+		Help: "Total number of tokens successfully published to the fan-out backend.",
+	})
+
+	publishErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "publish_errors_total",
+		Help: "Total number of errors publishing a token to the fan-out backend.",
+	})
+
+	timeToFirstTokenSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "time_to_first_token_seconds",
+		Help:    "Latency from request start to the first token being relayed, by model.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"model"})
+
+	interTokenLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "inter_token_latency_seconds",
+		Help:    "Latency between consecutive relayed tokens for a conversation, by model.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"model"})
+)
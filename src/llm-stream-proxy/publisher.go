@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// chatTokensStreamName is the shared JetStream stream backing every
+// conversation's subject so the bridge/SSE adapter can create durable or
+// ephemeral consumers against one place.
+const chatTokensStreamName = "CHAT_TOKENS"
+
+// chatTokensRetention bounds how long JetStream keeps delivered tokens
+// around for consumers that are slow to catch up.
+const chatTokensRetention = time.Hour
+
+// TokenPublisher fans a TokenMessage out to a streaming backend. publishToken
+// goes through this interface instead of calling a specific client directly,
+// so the transport can be swapped via PUBLISH_BACKEND without touching the
+// vLLM-facing streaming logic in streamFromLLM.
+//
+// There is no TokenSubscriber here: this proxy only ever publishes
+// (streamFromLLM -> publishToken), it never reads its own tokens back, so a
+// consumer-side interface in this package would have no caller. The actual
+// consumer abstraction lives with the actual consumers - the SSE adapter's
+// MessageBus (src/sse-adapter/messagebus.go) for the live services, and
+// demo/load-generator's TokenSubscriber for the test harness.
+type TokenPublisher interface {
+	Publish(ctx context.Context, msg TokenMessage) error
+	Close() error
+}
+
+// redisPublisher is the original transport: Pub/Sub for live fan-out plus a
+// capped Stream (see streamKey) for Last-Event-ID replay. It works the same
+// way whether rdb is a single node, a Sentinel failover group, or a Cluster.
+type redisPublisher struct {
+	rdb         redis.UniversalClient
+	clusterMode bool
+}
+
+func newRedisPublisher(rdb redis.UniversalClient, clusterMode bool) TokenPublisher {
+	return &redisPublisher{rdb: rdb, clusterMode: clusterMode}
+}
+
+func (p *redisPublisher) Publish(ctx context.Context, msg TokenMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal token: %w", err)
+	}
+
+	if err := p.rdb.Publish(ctx, p.channelName(msg.ConversationID), data).Err(); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+
+	// Also append to a capped Redis Stream so a consumer that connects late
+	// or reconnects mid-conversation can replay everything it missed instead
+	// of only seeing tokens published after it (re)subscribes.
+	if err := p.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.streamKeyName(msg.ConversationID),
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"data": string(data)},
+	}).Err(); err != nil {
+		return fmt.Errorf("xadd: %w", err)
+	}
+
+	return nil
+}
+
+// channelName returns the Pub/Sub channel for a conversation. In cluster
+// mode it wraps the conversation ID in a hash tag so the channel and its
+// paired stream key (below) always land on the same shard as each other.
+func (p *redisPublisher) channelName(conversationID string) string {
+	if p.clusterMode {
+		return fmt.Sprintf("chat.{%s}.tokens", conversationID)
+	}
+	return "chat." + conversationID + ".tokens"
+}
+
+// streamKeyName is streamKey's cluster-aware counterpart; see channelName.
+func (p *redisPublisher) streamKeyName(conversationID string) string {
+	if p.clusterMode {
+		return fmt.Sprintf("llm:stream:{%s}", conversationID)
+	}
+	return streamKey(conversationID)
+}
+
+func (p *redisPublisher) Close() error {
+	return p.rdb.Close()
+}
+
+// natsPublisher fans tokens out over NATS JetStream. Every conversation gets
+// its own subject (chat.<id>.tokens) on the shared CHAT_TOKENS stream;
+// subscribers create per-conversation ephemeral (or durable) consumers
+// against that stream rather than each getting their own broker-side state.
+type natsPublisher struct {
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+func newNATSPublisher(natsURL string) (TokenPublisher, error) {
+	nc, err := nats.Connect(natsURL, nats.Name("llm-stream-proxy"))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS: %w", err)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("creating JetStream context: %w", err)
+	}
+
+	if _, err := js.AddStream(&nats.StreamConfig{
+		Name:     chatTokensStreamName,
+		Subjects: []string{"chat.*.tokens"},
+		MaxAge:   chatTokensRetention,
+		Storage:  nats.FileStorage,
+	}); err != nil && err != nats.ErrStreamNameAlreadyInUse {
+		nc.Close()
+		return nil, fmt.Errorf("creating stream %s: %w", chatTokensStreamName, err)
+	}
+
+	return &natsPublisher{nc: nc, js: js}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, msg TokenMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal token: %w", err)
+	}
+
+	subject := "chat." + msg.ConversationID + ".tokens"
+	if _, err := p.js.Publish(subject, data); err != nil {
+		return fmt.Errorf("publish: %w", err)
+	}
+	return nil
+}
+
+func (p *natsPublisher) Close() error {
+	p.nc.Close()
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisMode selects how the proxy connects to Redis: a single node, a
+// Sentinel-managed failover group, or a Cluster.
+type RedisMode string
+
+const (
+	RedisModeStandalone RedisMode = "standalone"
+	RedisModeSentinel   RedisMode = "sentinel"
+	RedisModeCluster    RedisMode = "cluster"
+)
+
+// RedisConfig holds everything needed to build a Redis client in any mode.
+type RedisConfig struct {
+	Mode          RedisMode
+	Addr          string   // standalone
+	SentinelAddrs []string // sentinel
+	MasterName    string   // sentinel
+	ClusterAddrs  []string // cluster
+	Password      string
+	DB            int
+}
+
+func loadRedisConfig() RedisConfig {
+	return RedisConfig{
+		Mode:          RedisMode(getEnv("REDIS_MODE", string(RedisModeStandalone))),
+		Addr:          getEnv("REDIS_ADDR", "redis.redis-system.svc.cluster.local:6379"),
+		SentinelAddrs: splitAddrs(getEnv("REDIS_SENTINEL_ADDRS", "")),
+		MasterName:    getEnv("REDIS_MASTER_NAME", "mymaster"),
+		ClusterAddrs:  splitAddrs(getEnv("REDIS_CLUSTER_ADDRS", "")),
+		Password:      getEnv("REDIS_PASSWORD", ""),
+		DB:            getEnvInt("REDIS_DB", 0),
+	}
+}
+
+func splitAddrs(v string) []string {
+	if v == "" {
+		return nil
+	}
+	var addrs []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			addrs = append(addrs, part)
+		}
+	}
+	return addrs
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	var i int
+	if val := getEnv(key, ""); val != "" {
+		if _, err := fmt.Sscanf(val, "%d", &i); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
+
+// newRedisClient builds a redis.UniversalClient for the configured mode so
+// callers don't need to care whether they're talking to one node, a
+// Sentinel-fronted failover group, or a Cluster.
+func newRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	switch cfg.Mode {
+	case RedisModeStandalone:
+		return redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+		}), nil
+
+	case RedisModeSentinel:
+		if len(cfg.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("REDIS_SENTINEL_ADDRS is required for REDIS_MODE=sentinel")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    cfg.MasterName,
+			SentinelAddrs: cfg.SentinelAddrs,
+			Password:      cfg.Password,
+			DB:            cfg.DB,
+		}), nil
+
+	case RedisModeCluster:
+		if len(cfg.ClusterAddrs) == 0 {
+			return nil, fmt.Errorf("REDIS_CLUSTER_ADDRS is required for REDIS_MODE=cluster")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    cfg.ClusterAddrs,
+			Password: cfg.Password,
+		}), nil
+
+	default:
+		return nil, fmt.Errorf("unknown REDIS_MODE %q (want standalone, sentinel, or cluster)", cfg.Mode)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// chatTokensStreamName is the JetStream stream durable-log tokens are
+// appended to. It matches the stream the proxy's own NATS JetStream
+// publisher writes to when PUBLISH_BACKEND=nats, so one set of durable
+// consumers works regardless of which path produced a given conversation's
+// tokens.
+const chatTokensStreamName = "CHAT_TOKENS"
+
+// durableTokenStreamKey returns the Redis Stream key the bridge appends a
+// durable copy of each bridged token to.
+func durableTokenStreamKey(conversationID string) string {
+	return "llm:tokens:" + conversationID
+}
+
+// DurableLog is a durable, replayable copy of each conversation's token
+// stream, kept alongside the bridge's live core-bus fan-out so a client that
+// reconnects with Last-Event-ID can be handed everything it missed instead
+// of only tokens published after it resubscribes.
+type DurableLog interface {
+	Append(ctx context.Context, msg TokenMessage) error
+	Close() error
+}
+
+func newDurableLog(cfg *Config) (DurableLog, error) {
+	switch cfg.DurableLogKind {
+	case "none", "":
+		return noopDurableLog{}, nil
+	case "jetstream":
+		return newJetStreamDurableLog(cfg)
+	case "redis":
+		return newRedisStreamDurableLog(cfg)
+	default:
+		return nil, fmt.Errorf("unknown DURABLE_LOG_KIND %q (want jetstream, redis, or none)", cfg.DurableLogKind)
+	}
+}
+
+type noopDurableLog struct{}
+
+func (noopDurableLog) Append(ctx context.Context, msg TokenMessage) error { return nil }
+func (noopDurableLog) Close() error                                       { return nil }
+
+// jetStreamDurableLog backs the durable log with its own connection to the
+// CHAT_TOKENS JetStream stream (independent of the core-bus NATS connection,
+// since BUS_KIND may be rabbitmq while the durable log still wants JetStream).
+type jetStreamDurableLog struct {
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+func newJetStreamDurableLog(cfg *Config) (*jetStreamDurableLog, error) {
+	nc, err := nats.Connect(cfg.DurableLogNATSURL, nats.Name(cfg.InstanceID+"-durable-log"))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS for durable log: %w", err)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("creating JetStream context: %w", err)
+	}
+
+	if _, err := js.AddStream(&nats.StreamConfig{
+		Name:     chatTokensStreamName,
+		Subjects: []string{"chat.*.tokens"},
+		MaxAge:   cfg.DurableLogRetention,
+		Storage:  nats.FileStorage,
+	}); err != nil && err != nats.ErrStreamNameAlreadyInUse {
+		nc.Close()
+		return nil, fmt.Errorf("creating stream %s: %w", chatTokensStreamName, err)
+	}
+
+	return &jetStreamDurableLog{nc: nc, js: js}, nil
+}
+
+func (l *jetStreamDurableLog) Append(ctx context.Context, msg TokenMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal token: %w", err)
+	}
+
+	subject := "chat." + msg.ConversationID + ".tokens"
+	if _, err := l.js.Publish(subject, data); err != nil {
+		return fmt.Errorf("publish to durable log: %w", err)
+	}
+	return nil
+}
+
+func (l *jetStreamDurableLog) Close() error {
+	l.nc.Close()
+	return nil
+}
+
+// redisStreamDurableLog backs the durable log with a plain Redis Stream per
+// conversation, trimmed by age rather than count since conversations vary
+// wildly in token volume.
+type redisStreamDurableLog struct {
+	rdb       redis.UniversalClient
+	retention time.Duration
+}
+
+func newRedisStreamDurableLog(cfg *Config) (*redisStreamDurableLog, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("connecting to Redis for durable log: %w", err)
+	}
+	return &redisStreamDurableLog{rdb: rdb, retention: cfg.DurableLogRetention}, nil
+}
+
+func (l *redisStreamDurableLog) Append(ctx context.Context, msg TokenMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal token: %w", err)
+	}
+
+	key := durableTokenStreamKey(msg.ConversationID)
+	if err := l.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		Values: map[string]interface{}{"data": string(data)},
+	}).Err(); err != nil {
+		return fmt.Errorf("xadd: %w", err)
+	}
+
+	cutoff := time.Now().Add(-l.retention).UnixMilli()
+	l.rdb.XTrimMinIDApprox(ctx, key, fmt.Sprintf("%d-0", cutoff), 0)
+
+	return nil
+}
+
+func (l *redisStreamDurableLog) Close() error {
+	return l.rdb.Close()
+}
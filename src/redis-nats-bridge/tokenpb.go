@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// encodeToken serializes msg for the bus, in the format selected by
+// cfg.WireFormat ("json" or "protobuf"). Field numbers match token.proto.
+func encodeToken(msg TokenMessage, format string) ([]byte, error) {
+	switch format {
+	case "protobuf":
+		return marshalTokenProto(msg), nil
+	case "json", "":
+		return json.Marshal(msg)
+	default:
+		return nil, fmt.Errorf("unknown wire format %q (want json or protobuf)", format)
+	}
+}
+
+// marshalTokenProto hand-encodes TokenMessage using the protobuf wire
+// format directly (no protoc-gen-go step), since every field here is a
+// scalar or string/bytes that protowire covers on its own.
+func marshalTokenProto(msg TokenMessage) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, msg.ConversationID)
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, []byte(msg.Token))
+	if msg.Sequence != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(msg.Sequence))
+	}
+	if msg.Done {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if msg.Timestamp != 0 {
+		b = protowire.AppendTag(b, 5, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(msg.Timestamp))
+	}
+	if msg.ContentType != "" {
+		b = protowire.AppendTag(b, 6, protowire.BytesType)
+		b = protowire.AppendString(b, msg.ContentType)
+	}
+	return b
+}
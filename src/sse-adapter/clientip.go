@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies parses a comma-separated list of CIDRs (e.g.
+// "10.0.0.0/8,172.16.0.0/12") into net.IPNets. Entries that fail to parse
+// are skipped with a log line rather than failing startup, since a typo
+// here should degrade to "trust nothing" rather than crash the adapter.
+func parseTrustedProxies(cidrs string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, raw := range strings.Split(cidrs, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(raw)
+		if err != nil {
+			slog.Warn("Ignoring unparseable trusted proxy CIDR", "cidr", raw, "error", err)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func isTrustedAddr(ip net.IP, trusted []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveClientIP returns the real client IP for r. Proxy headers
+// (X-Real-IP, X-Forwarded-For) are only honored when RemoteAddr itself is
+// inside a trusted CIDR - otherwise the request could be spoofing them, so
+// RemoteAddr is used as-is. When RemoteAddr is trusted, X-Real-IP wins if
+// present; otherwise X-Forwarded-For is walked right-to-left, skipping any
+// entries that are themselves trusted proxies, and the first untrusted
+// entry found is the client. If every entry turns out to be trusted (or the
+// header is absent/unparseable), RemoteAddr is returned.
+func resolveClientIP(r *http.Request, trusted []*net.IPNet) string {
+	remoteHost, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteHost = r.RemoteAddr
+	}
+	remoteIP := net.ParseIP(remoteHost)
+
+	if !isTrustedAddr(remoteIP, trusted) {
+		return remoteHost
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	xff := r.Header.Get("X-Forwarded-For")
+	if xff == "" {
+		return remoteHost
+	}
+
+	entries := strings.Split(xff, ",")
+	for i := len(entries) - 1; i >= 0; i-- {
+		candidate := strings.TrimSpace(entries[i])
+		ip := net.ParseIP(candidate)
+		if ip == nil {
+			break // unparseable entry; fall back to RemoteAddr
+		}
+		if isTrustedAddr(ip, trusted) {
+			continue // another hop in our own proxy chain; keep looking left
+		}
+		return candidate
+	}
+
+	return remoteHost
+}
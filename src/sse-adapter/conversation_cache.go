@@ -0,0 +1,162 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// conversationCacheSize bounds the number of conversations kept in RAM; the
+// least-recently-used conversation is evicted to make room for a new one.
+const conversationCacheSize = 1000
+
+// conversationRingSize bounds how many recent tokens are retained in RAM per
+// conversation. It's smaller than the proxy's streamMaxLen since this cache
+// only needs to cover the short window a reconnect or a second subscriber
+// might miss, not a full conversation history.
+const conversationRingSize = 256
+
+// conversationGracePeriod keeps a completed conversation's tokens cached for
+// a little while after its Done token, so a client that reconnects moments
+// after the stream finished still gets a fast in-memory replay instead of
+// falling through to Redis.
+const conversationGracePeriod = 30 * time.Second
+
+// cachedToken pairs a decoded token with the Redis Stream ID it was read
+// from, so a cache hit can resume XREAD tailing without re-reading history
+// via XRANGE.
+type cachedToken struct {
+	token   *TokenMessage
+	redisID string
+}
+
+type cacheEntry struct {
+	conversationID string
+	tokens         []cachedToken // ring buffer, oldest first
+	doneAt         time.Time     // zero until a Done token has been appended
+}
+
+// ConversationCache is an in-process, read-through cache of recently seen
+// conversation token streams, keyed by conversation ID. It sits in front of
+// Redis: streamFromRedis appends every token it reads here as it goes, so a
+// second subscriber for the same conversation - a reconnect, or a second
+// browser tab - can replay from RAM and resume tailing from the cached Redis
+// Stream ID instead of re-reading Redis Stream history. It is local to one
+// adapter instance and is not shared across replicas.
+type ConversationCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // conversationID -> element in lru
+	lru     *list.List               // front = most recently used
+}
+
+func NewConversationCache() *ConversationCache {
+	return &ConversationCache{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Append records token, read from Redis Stream entry redisID, for
+// conversationID, evicting the oldest cached conversation if this is a new
+// one and the cache is full.
+func (c *ConversationCache) Append(conversationID string, token *TokenMessage, redisID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictExpiredLocked()
+
+	el, ok := c.entries[conversationID]
+	var entry *cacheEntry
+	if ok {
+		entry = el.Value.(*cacheEntry)
+		c.lru.MoveToFront(el)
+	} else {
+		entry = &cacheEntry{conversationID: conversationID}
+		c.entries[conversationID] = c.lru.PushFront(entry)
+		if len(c.entries) > conversationCacheSize {
+			c.evictOldestLocked()
+		}
+	}
+
+	// Every connection tailing this conversation calls Append with the same
+	// tokens it reads, so without this the ring fills with duplicates.
+	// Sequence is monotonic, so anything at or behind what's already cached
+	// is a repeat (or a late, out-of-order delivery) and can be dropped.
+	if n := len(entry.tokens); n > 0 && token.Sequence <= entry.tokens[n-1].token.Sequence {
+		return
+	}
+
+	// Store our own copy rather than the caller's pointer: that same
+	// pointer is also handed to msgChan and may be mutated in place by the
+	// inspection pipeline's redact path (token.Token = ...). Without this,
+	// a redact for one connection would silently rewrite what every future
+	// Replay serves to other connections.
+	stored := *token
+	entry.tokens = append(entry.tokens, cachedToken{token: &stored, redisID: redisID})
+	if len(entry.tokens) > conversationRingSize {
+		entry.tokens = entry.tokens[len(entry.tokens)-conversationRingSize:]
+	}
+	if token.Done {
+		entry.doneAt = time.Now()
+	}
+}
+
+// Replay returns cached tokens for conversationID with Sequence >
+// afterSequence, the Redis Stream ID of the most recent cached token (for
+// resuming XREAD tailing), whether the conversation was present in the cache
+// at all (a cache hit, even if there was nothing new to replay), and whether
+// the cached ring actually covers afterSequence with no gap - i.e. its
+// oldest entry is at or before afterSequence+1. A caller must only skip its
+// own Redis history read when covered is true; a ring that has rotated past
+// afterSequence is a hit but not a substitute for XRANGE.
+func (c *ConversationCache) Replay(conversationID string, afterSequence int64) (tokens []*TokenMessage, lastRedisID string, hit bool, covered bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[conversationID]
+	if !ok {
+		return nil, "", false, false
+	}
+	c.lru.MoveToFront(el)
+
+	entry := el.Value.(*cacheEntry)
+	for _, ct := range entry.tokens {
+		if ct.token.Sequence > afterSequence {
+			// Hand back a copy, not our stored pointer: the caller forwards
+			// this straight to msgChan, and the inspection pipeline may
+			// mutate it in place on redact. Mutating our own cached entry
+			// would corrupt what every later Replay of this conversation
+			// serves.
+			tok := *ct.token
+			tokens = append(tokens, &tok)
+		}
+	}
+	if n := len(entry.tokens); n > 0 {
+		lastRedisID = entry.tokens[n-1].redisID
+		covered = entry.tokens[0].token.Sequence <= afterSequence+1
+	}
+	return tokens, lastRedisID, true, covered
+}
+
+func (c *ConversationCache) evictOldestLocked() {
+	oldest := c.lru.Back()
+	if oldest == nil {
+		return
+	}
+	entry := oldest.Value.(*cacheEntry)
+	delete(c.entries, entry.conversationID)
+	c.lru.Remove(oldest)
+}
+
+// evictExpiredLocked drops completed conversations past their grace period,
+// bounding memory use by finished conversations nobody reconnects for.
+func (c *ConversationCache) evictExpiredLocked() {
+	now := time.Now()
+	for id, el := range c.entries {
+		entry := el.Value.(*cacheEntry)
+		if !entry.doneAt.IsZero() && now.Sub(entry.doneAt) >= conversationGracePeriod {
+			delete(c.entries, id)
+			c.lru.Remove(el)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// chatTokensStreamName is the JetStream stream the bridge's durable log
+// appends to; see chatTokensStreamName in the redis-nats-bridge service.
+const chatTokensStreamName = "CHAT_TOKENS"
+
+// durableTokenStreamKey returns the Redis Stream key the bridge's durable
+// log appends a durable copy of each bridged token to.
+func durableTokenStreamKey(conversationID string) string {
+	return "llm:tokens:" + conversationID
+}
+
+// DurableLog replays a conversation's durable token history so a client
+// reconnecting with Last-Event-ID can be caught up before HandleStream
+// switches to the live bus subscription. It's the read side of the bridge's
+// DurableLog.
+type DurableLog interface {
+	// Replay returns, in sequence order, every durable log entry for
+	// conversationID with Sequence > afterSequence. evicted is true when
+	// afterSequence is older than the log's retention window, meaning some
+	// of what the caller wanted may already be gone.
+	Replay(ctx context.Context, conversationID string, afterSequence int64) (tokens []TokenMessage, evicted bool, err error)
+	Close() error
+}
+
+func newDurableLog(cfg *Config) (DurableLog, error) {
+	switch cfg.DurableLogKind {
+	case "none", "":
+		return nil, nil
+	case "jetstream":
+		return newJetStreamDurableLog(cfg)
+	case "redis":
+		return newRedisStreamDurableLog(cfg)
+	default:
+		return nil, fmt.Errorf("unknown DURABLE_LOG_KIND %q (want jetstream, redis, or none)", cfg.DurableLogKind)
+	}
+}
+
+// jetStreamDurableLog reads CHAT_TOKENS over its own NATS connection,
+// independent of the live bus connection (which may be RabbitMQ).
+type jetStreamDurableLog struct {
+	nc *nats.Conn
+	js nats.JetStreamContext
+}
+
+func newJetStreamDurableLog(cfg *Config) (*jetStreamDurableLog, error) {
+	nc, err := nats.Connect(cfg.DurableLogNATSURL, nats.Name("sse-adapter-durable-log"))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS for durable log: %w", err)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("creating JetStream context: %w", err)
+	}
+
+	return &jetStreamDurableLog{nc: nc, js: js}, nil
+}
+
+// Replay creates a throwaway ephemeral pull consumer over CHAT_TOKENS,
+// filtered to this conversation's subject, and drains everything currently
+// stored. JetStream's own retention (MaxAge, configured when the bridge
+// creates the stream) is what makes afterSequence "evicted" when it falls
+// outside that window.
+func (l *jetStreamDurableLog) Replay(ctx context.Context, conversationID string, afterSequence int64) ([]TokenMessage, bool, error) {
+	subject := "chat." + conversationID + ".tokens"
+
+	sub, err := l.js.PullSubscribe(subject, "", nats.DeliverAll(), nats.ReplayInstant())
+	if err != nil {
+		return nil, false, fmt.Errorf("creating ephemeral consumer: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	var tokens []TokenMessage
+	evicted := false
+	first := true
+
+	for {
+		msgs, err := sub.Fetch(100, nats.MaxWait(500*time.Millisecond))
+		if err != nil {
+			if err == nats.ErrTimeout {
+				break
+			}
+			return nil, false, fmt.Errorf("fetching durable log: %w", err)
+		}
+		if len(msgs) == 0 {
+			break
+		}
+
+		for _, m := range msgs {
+			m.Ack()
+
+			var token TokenMessage
+			if err := json.Unmarshal(m.Data, &token); err != nil {
+				continue
+			}
+
+			if first {
+				first = false
+				if afterSequence > 0 && token.Sequence > afterSequence+1 {
+					evicted = true
+				}
+			}
+
+			if token.Sequence > afterSequence {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+
+	return tokens, evicted, nil
+}
+
+func (l *jetStreamDurableLog) Close() error {
+	l.nc.Close()
+	return nil
+}
+
+type redisStreamDurableLog struct {
+	rdb redis.UniversalClient
+}
+
+func newRedisStreamDurableLog(cfg *Config) (*redisStreamDurableLog, error) {
+	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		return nil, fmt.Errorf("connecting to Redis for durable log: %w", err)
+	}
+	return &redisStreamDurableLog{rdb: rdb}, nil
+}
+
+func (l *redisStreamDurableLog) Replay(ctx context.Context, conversationID string, afterSequence int64) ([]TokenMessage, bool, error) {
+	key := durableTokenStreamKey(conversationID)
+
+	entries, err := l.rdb.XRange(ctx, key, "-", "+").Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("xrange: %w", err)
+	}
+
+	var tokens []TokenMessage
+	evicted := false
+	for i, entry := range entries {
+		raw, ok := entry.Values["data"].(string)
+		if !ok {
+			continue
+		}
+		var token TokenMessage
+		if err := json.Unmarshal([]byte(raw), &token); err != nil {
+			continue
+		}
+		if i == 0 && afterSequence > 0 && token.Sequence > afterSequence+1 {
+			evicted = true
+		}
+		if token.Sequence > afterSequence {
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, evicted, nil
+}
+
+func (l *redisStreamDurableLog) Close() error {
+	return l.rdb.Close()
+}
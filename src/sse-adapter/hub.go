@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// hubClientBuffer is the size of each client's ring buffer. It's
+// intentionally small: a client that falls this far behind is better served
+// by a "lag" notice and a jump forward than by an ever-growing backlog.
+const hubClientBuffer = 64
+
+// hubLinger is how long a conversation's bus subscription is kept alive
+// after its last client leaves, so a quick reconnect doesn't pay the cost
+// of resubscribing and re-registering from scratch.
+const hubLinger = 10 * time.Second
+
+var (
+	hubConversations = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "sse_hub_conversations",
+		Help: "Number of conversations with an active Hub subscription.",
+	})
+
+	// A per-conversation_id label here would be unbounded cardinality (one
+	// series per UUID, for the lifetime of the process minus whatever
+	// DeleteLabelValues catches on the way out), so this tracks the
+	// distribution of client counts across all conversations instead of a
+	// series per conversation.
+	hubClientsPerConversation = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sse_hub_clients_per_conversation",
+		Help:    "Distribution of client counts per conversation in the Hub, sampled on every join/leave.",
+		Buckets: []float64{1, 2, 5, 10, 25, 50, 100, 250},
+	})
+
+	slowConsumerDropsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sse_slow_consumer_drops_total",
+		Help: "Total number of tokens dropped from a client's ring buffer because it fell too far behind.",
+	})
+)
+
+// hubClient is one registered viewer of a conversation. tokens is its
+// bounded ring buffer; lag carries the sequence range dropped the last time
+// the buffer overflowed, surfaced to HandleStream as an "event: lag" frame.
+type hubClient struct {
+	tokens chan *TokenMessage
+	lag    chan [2]int64
+}
+
+// hubEntry is the shared state for one conversation: a single bus
+// subscription broadcasting to every registered client.
+type hubEntry struct {
+	mu        sync.Mutex
+	sub       Subscription
+	clients   map[*hubClient]struct{}
+	lingerTmr *time.Timer
+}
+
+// Hub multiplexes one bus subscription per conversation across any number
+// of SSE clients, so a popular conversation doesn't open one subscription
+// (and one 100-slot channel) per viewer. The first client to join a
+// conversation creates its hubEntry; the last to leave tears it down after
+// a short linger.
+type Hub struct {
+	bus        MessageBus
+	wireFormat string // "json" or "protobuf"; decoding used for bus payloads
+
+	mu      sync.Mutex
+	entries map[string]*hubEntry
+}
+
+// NewHub creates a Hub that fans out bus messages through it, decoding each
+// payload per wireFormat (see decodeToken).
+func NewHub(bus MessageBus, wireFormat string) *Hub {
+	return &Hub{
+		bus:        bus,
+		wireFormat: wireFormat,
+		entries:    make(map[string]*hubEntry),
+	}
+}
+
+// Join registers a new client for conversationID, subscribing to the bus on
+// its behalf if no other client is currently watching it. The returned
+// hubClient's tokens/lag channels receive broadcasts until Leave is called;
+// Leave must be called exactly once per successful Join.
+func (h *Hub) Join(conversationID string) (*hubClient, error) {
+	h.mu.Lock()
+	entry, ok := h.entries[conversationID]
+	if !ok {
+		entry = &hubEntry{clients: make(map[*hubClient]struct{})}
+		h.entries[conversationID] = entry
+		hubConversations.Inc()
+	}
+	h.mu.Unlock()
+
+	entry.mu.Lock()
+
+	if entry.lingerTmr != nil {
+		entry.lingerTmr.Stop()
+		entry.lingerTmr = nil
+	}
+
+	if entry.sub == nil {
+		subject := fmt.Sprintf("chat.%s.tokens", conversationID)
+		sub, err := h.bus.Subscribe(subject, func(_ string, data []byte) {
+			h.broadcast(conversationID, data)
+		})
+		if err != nil {
+			// Release entry.mu before taking h.mu in removeEntryIfEmpty:
+			// every other path locks h.mu first, then entry.mu, so holding
+			// entry.mu across that call would invert the lock order.
+			entry.mu.Unlock()
+			h.removeEntryIfEmpty(conversationID)
+			return nil, fmt.Errorf("subscribing hub entry for %s: %w", conversationID, err)
+		}
+		entry.sub = sub
+	}
+
+	client := &hubClient{
+		tokens: make(chan *TokenMessage, hubClientBuffer),
+		lag:    make(chan [2]int64, 1),
+	}
+	entry.clients[client] = struct{}{}
+	hubClientsPerConversation.Observe(float64(len(entry.clients)))
+	entry.mu.Unlock()
+
+	return client, nil
+}
+
+// Leave unregisters client from conversationID. Once the last client leaves,
+// the bus subscription lingers for hubLinger before it's actually closed.
+func (h *Hub) Leave(conversationID string, client *hubClient) {
+	h.mu.Lock()
+	entry, ok := h.entries[conversationID]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	entry.mu.Lock()
+	delete(entry.clients, client)
+	remaining := len(entry.clients)
+	hubClientsPerConversation.Observe(float64(remaining))
+	if remaining > 0 {
+		entry.mu.Unlock()
+		return
+	}
+
+	entry.lingerTmr = time.AfterFunc(hubLinger, func() {
+		h.removeEntryIfStillEmpty(conversationID)
+	})
+	entry.mu.Unlock()
+}
+
+// broadcast delivers a raw bus message to every client currently registered
+// for conversationID, applying each client's slow-consumer policy
+// independently: drop the oldest buffered token, notify via lag, then
+// enqueue the new one.
+func (h *Hub) broadcast(conversationID string, data []byte) {
+	h.mu.Lock()
+	entry, ok := h.entries[conversationID]
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	token, err := decodeToken(data, h.wireFormat)
+	if err != nil {
+		slog.Error("Hub failed to decode token", "error", err, "conversation_id", conversationID, "wire_format", h.wireFormat)
+		return
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	for client := range entry.clients {
+		// Each client gets its own copy: the inline/hybrid inspection
+		// pipeline mutates a delivered token in place on redact
+		// (token.Token = ...), and every field here is a plain value, so a
+		// shared *token would let one viewer's redact corrupt what every
+		// other viewer on this conversation is concurrently reading.
+		tokenCopy := token
+
+		select {
+		case client.tokens <- &tokenCopy:
+			continue
+		default:
+		}
+
+		// Buffer is full: drop the oldest entry to make room, and tell the
+		// client what range it lost so it can report the gap.
+		var dropped *TokenMessage
+		select {
+		case dropped = <-client.tokens:
+		default:
+		}
+		slowConsumerDropsTotal.Inc()
+
+		if dropped != nil {
+			lagRange := [2]int64{dropped.Sequence, dropped.Sequence}
+			select {
+			case client.lag <- lagRange:
+			default:
+				// A lag notice is already pending; widening it is not worth
+				// the complexity here, so the client just sees the first gap.
+			}
+		}
+
+		select {
+		case client.tokens <- &tokenCopy:
+		default:
+			// Buffer refilled between the drop and the retry (unlikely
+			// given entry.mu is held); drop this token too rather than block.
+			slowConsumerDropsTotal.Inc()
+		}
+	}
+}
+
+func (h *Hub) removeEntryIfEmpty(conversationID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if entry, ok := h.entries[conversationID]; ok && len(entry.clients) == 0 {
+		delete(h.entries, conversationID)
+		hubConversations.Dec()
+	}
+}
+
+func (h *Hub) removeEntryIfStillEmpty(conversationID string) {
+	h.mu.Lock()
+	entry, ok := h.entries[conversationID]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+	entry.mu.Lock()
+	if len(entry.clients) != 0 {
+		entry.mu.Unlock()
+		h.mu.Unlock()
+		return
+	}
+	sub := entry.sub
+	entry.sub = nil
+	entry.mu.Unlock()
+	delete(h.entries, conversationID)
+	h.mu.Unlock()
+
+	hubConversations.Dec()
+	if sub != nil {
+		if err := sub.Unsubscribe(); err != nil {
+			slog.Error("Hub failed to unsubscribe", "error", err, "conversation_id", conversationID)
+		}
+	}
+}
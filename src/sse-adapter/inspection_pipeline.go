@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// inspectionWorkerPoolSize bounds how many Inspect calls run concurrently
+// across every connection, so a burst of connections can't turn inspection
+// into an unbounded fan-out of outbound HTTP requests.
+const inspectionWorkerPoolSize = 16
+
+// inspectionJobQueueSize is how many pending inspection jobs can queue
+// before further submissions fail open with an Allow verdict (see inspect).
+// Generous relative to inspectionWorkerPoolSize since inline mode submits
+// and waits inline.
+const inspectionJobQueueSize = 256
+
+var (
+	inspectionVerdictLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sse_inspection_verdict_latency_seconds",
+		Help:    "Latency of Inspector.Inspect calls.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .15, .25, .5, 1, 2},
+	})
+
+	inspectionActionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sse_inspection_actions_total",
+		Help: "Total number of inspection verdicts, by action and pipeline mode.",
+	}, []string{"action", "mode"})
+
+	inspectionErrorsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sse_inspection_errors_total",
+		Help: "Total number of Inspector.Inspect calls that returned an error.",
+	})
+)
+
+// inspectionVerdict is what a worker pool job reports back for one token.
+type inspectionVerdict struct {
+	action  Action
+	content string // redacted content when action == ActionRedact
+	err     error
+}
+
+// inspectionJob is one unit of work for the shared worker pool.
+type inspectionJob struct {
+	ctx    context.Context
+	token  *TokenMessage
+	result chan<- inspectionVerdict
+}
+
+// Pipeline wraps a raw token channel (e.g. msgChan's source) with the
+// inspection behavior selected by Config.InspectionMode, applying an
+// Inspector's verdicts before (inline/hybrid) or alongside (async) delivery
+// to the SSE client. One Pipeline's worker pool is shared by every
+// connection's Wrap call.
+type Pipeline struct {
+	inspector Inspector
+	mode      string
+	buffer    time.Duration
+
+	jobs chan inspectionJob
+}
+
+// NewPipeline starts the shared worker pool and returns a Pipeline. inspector
+// may be nil, in which case Wrap passes tokens through unmodified (this is
+// what Config.InspectionMode == "disabled" gets via newPipeline below).
+func NewPipeline(inspector Inspector, mode string, buffer time.Duration) *Pipeline {
+	p := &Pipeline{
+		inspector: inspector,
+		mode:      mode,
+		buffer:    buffer,
+		jobs:      make(chan inspectionJob, inspectionJobQueueSize),
+	}
+	if inspector != nil {
+		for i := 0; i < inspectionWorkerPoolSize; i++ {
+			go p.worker()
+		}
+	}
+	return p
+}
+
+func (p *Pipeline) worker() {
+	for job := range p.jobs {
+		start := time.Now()
+		action, content, err := p.inspector.Inspect(job.ctx, job.token)
+		inspectionVerdictLatency.Observe(time.Since(start).Seconds())
+		if err != nil {
+			inspectionErrorsTotal.Inc()
+		}
+		job.result <- inspectionVerdict{action: action, content: content, err: err}
+	}
+}
+
+// inspect submits token to the shared worker pool and returns a channel
+// that receives exactly one verdict.
+func (p *Pipeline) inspect(ctx context.Context, token *TokenMessage) <-chan inspectionVerdict {
+	result := make(chan inspectionVerdict, 1)
+	select {
+	case p.jobs <- inspectionJob{ctx: ctx, token: token, result: result}:
+	default:
+		// Worker pool is saturated; fail open rather than block the
+		// connection that's trying to submit a job.
+		inspectionErrorsTotal.Inc()
+		result <- inspectionVerdict{action: ActionAllow, err: context.DeadlineExceeded}
+	}
+	return result
+}
+
+// pipelineOutput is what Wrap delivers downstream: either a token to render
+// as an "event: token" frame, or an alert referencing a sequence that was
+// already delivered (async mode only) and should be flagged after the fact
+// with an "event: alert" frame.
+type pipelineOutput struct {
+	token         *TokenMessage
+	alert         bool // when true, alertSequence is the sequence being flagged; token itself is nil
+	alertSequence int64
+}
+
+// Wrap consumes in until doneChan closes, applying this Pipeline's mode, and
+// sends results to the returned channel. When inspector is nil (inspection
+// disabled), it degenerates to a pass-through goroutine.
+func (p *Pipeline) Wrap(ctx context.Context, in <-chan *TokenMessage, doneChan <-chan struct{}) <-chan pipelineOutput {
+	out := make(chan pipelineOutput, 100)
+
+	if p.inspector == nil {
+		go func() {
+			for {
+				select {
+				case <-doneChan:
+					return
+				case token := <-in:
+					select {
+					case out <- pipelineOutput{token: token}:
+					case <-doneChan:
+						return
+					}
+				}
+			}
+		}()
+		return out
+	}
+
+	switch p.mode {
+	case "async":
+		go p.runAsync(ctx, in, doneChan, out)
+	case "hybrid":
+		go p.runHybrid(ctx, in, doneChan, out)
+	default: // "inline" and any unrecognized value fail closed to the safest mode
+		go p.runInline(ctx, in, doneChan, out)
+	}
+	return out
+}
+
+// InspectOne applies this Pipeline's inspector to a single token outside of
+// Wrap's channel plumbing, for call sites - durable-log replay, so far -
+// that write directly to the ResponseWriter instead of feeding msgChan. It
+// blocks for the verdict and applies Drop/Redact exactly like runInline,
+// reporting whether the (possibly redacted) token should still be
+// delivered. When inspection is disabled it always returns true.
+func (p *Pipeline) InspectOne(ctx context.Context, token *TokenMessage) bool {
+	if p.inspector == nil {
+		return true
+	}
+
+	verdict := <-p.inspect(ctx, token)
+	inspectionActionsTotal.WithLabelValues(verdict.action.String(), "replay").Inc()
+
+	switch verdict.action {
+	case ActionDrop:
+		return false
+	case ActionRedact:
+		token.Token = verdict.content
+	}
+	return true
+}
+
+// runInline blocks delivery of each token until its verdict (or the
+// inspector's own timeout) returns, then applies Drop/Redact before sending.
+func (p *Pipeline) runInline(ctx context.Context, in <-chan *TokenMessage, doneChan <-chan struct{}, out chan<- pipelineOutput) {
+	for {
+		select {
+		case <-doneChan:
+			return
+		case token := <-in:
+			verdict := <-p.inspect(ctx, token)
+			inspectionActionsTotal.WithLabelValues(verdict.action.String(), "inline").Inc()
+
+			switch verdict.action {
+			case ActionDrop:
+				continue
+			case ActionRedact:
+				token.Token = verdict.content
+			}
+
+			select {
+			case out <- pipelineOutput{token: token}:
+			case <-doneChan:
+				return
+			}
+		}
+	}
+}
+
+// runAsync delivers every token immediately and inspects in the background;
+// a non-Allow verdict surfaces as a trailing alert rather than blocking or
+// retracting delivery.
+func (p *Pipeline) runAsync(ctx context.Context, in <-chan *TokenMessage, doneChan <-chan struct{}, out chan<- pipelineOutput) {
+	for {
+		select {
+		case <-doneChan:
+			return
+		case token := <-in:
+			select {
+			case out <- pipelineOutput{token: token}:
+			case <-doneChan:
+				return
+			}
+
+			verdictCh := p.inspect(ctx, token)
+			go func(seq int64) {
+				verdict := <-verdictCh
+				inspectionActionsTotal.WithLabelValues(verdict.action.String(), "async").Inc()
+				if verdict.action == ActionAllow {
+					return
+				}
+				select {
+				case out <- pipelineOutput{alert: true, alertSequence: seq}:
+				case <-doneChan:
+				}
+			}(token.Sequence)
+		}
+	}
+}
+
+// hybridItem is one token buffered by runHybrid while its verdict is
+// pending.
+type hybridItem struct {
+	token    *TokenMessage
+	verdicts <-chan inspectionVerdict
+	deadline time.Time
+}
+
+// runHybrid buffers each token for up to p.buffer, flushing strictly in
+// arrival order: a token is sent downstream as soon as its own verdict
+// returns or its deadline passes, whichever is first, but never before the
+// token ahead of it has already been flushed.
+func (p *Pipeline) runHybrid(ctx context.Context, in <-chan *TokenMessage, doneChan <-chan struct{}, out chan<- pipelineOutput) {
+	queue := make(chan hybridItem, inspectionJobQueueSize)
+
+	go func() {
+		defer close(queue)
+		for {
+			select {
+			case <-doneChan:
+				return
+			case token := <-in:
+				item := hybridItem{
+					token:    token,
+					verdicts: p.inspect(ctx, token),
+					deadline: time.Now().Add(p.buffer),
+				}
+				select {
+				case queue <- item:
+				case <-doneChan:
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var item hybridItem
+		var ok bool
+		select {
+		case <-doneChan:
+			return
+		case item, ok = <-queue:
+			if !ok {
+				return
+			}
+		}
+
+		var verdict inspectionVerdict
+		select {
+		case verdict = <-item.verdicts:
+		case <-time.After(time.Until(item.deadline)):
+			verdict = inspectionVerdict{action: ActionAllow}
+		case <-doneChan:
+			return
+		}
+		inspectionActionsTotal.WithLabelValues(verdict.action.String(), "hybrid").Inc()
+
+		switch verdict.action {
+		case ActionDrop:
+			continue
+		case ActionRedact:
+			item.token.Token = verdict.content
+		}
+
+		select {
+		case out <- pipelineOutput{token: item.token}:
+		case <-doneChan:
+			return
+		}
+	}
+}
+
+// newPipeline builds the Pipeline configured by cfg. When inspection is
+// disabled or no endpoint is set, it returns a Pipeline with a nil
+// inspector, whose Wrap passes tokens through unmodified.
+func newPipeline(cfg *Config) *Pipeline {
+	if cfg.InspectionMode == "" || cfg.InspectionMode == "disabled" || cfg.InspectionEndpoint == "" {
+		return NewPipeline(nil, cfg.InspectionMode, cfg.InspectionBuffer)
+	}
+	slog.Info("Inspection pipeline enabled", "mode", cfg.InspectionMode, "endpoint", cfg.InspectionEndpoint)
+	return NewPipeline(newHTTPInspector(cfg.InspectionEndpoint), cfg.InspectionMode, cfg.InspectionBuffer)
+}
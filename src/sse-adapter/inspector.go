@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Action is the verdict an Inspector returns for a single token.
+type Action int
+
+const (
+	// ActionAllow delivers the token unmodified.
+	ActionAllow Action = iota
+	// ActionRedact delivers the token with its content replaced.
+	ActionRedact
+	// ActionDrop withholds the token from the client entirely.
+	ActionDrop
+	// ActionAlert delivers the token. Only async mode flags it to the
+	// client as suspect, with a trailing alert frame; inline and hybrid
+	// modes deliver it exactly like ActionAllow.
+	ActionAlert
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionRedact:
+		return "redact"
+	case ActionDrop:
+		return "drop"
+	case ActionAlert:
+		return "alert"
+	default:
+		return "unknown"
+	}
+}
+
+func parseAction(s string) (Action, error) {
+	switch s {
+	case "allow", "":
+		return ActionAllow, nil
+	case "redact":
+		return ActionRedact, nil
+	case "drop":
+		return ActionDrop, nil
+	case "alert":
+		return ActionAlert, nil
+	default:
+		return ActionAllow, fmt.Errorf("unknown inspector action %q", s)
+	}
+}
+
+// inspectorCallTimeout bounds a single Inspect call, regardless of
+// InspectionMode, so a slow or hung inspection endpoint can't stall inline
+// delivery or leak goroutines in async/hybrid mode.
+const inspectorCallTimeout = 2 * time.Second
+
+// Inspector screens a single token before (or shortly after) it's delivered
+// to an SSE client. redactedContent is only meaningful when the returned
+// Action is ActionRedact.
+type Inspector interface {
+	Inspect(ctx context.Context, token *TokenMessage) (action Action, redactedContent string, err error)
+}
+
+// httpInspector implements Inspector by POSTing the token to an external
+// HTTP endpoint (Config.InspectionEndpoint) and parsing its verdict.
+type httpInspector struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newHTTPInspector(endpoint string) *httpInspector {
+	return &httpInspector{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: inspectorCallTimeout},
+	}
+}
+
+type inspectionRequest struct {
+	ConversationID string `json:"conversation_id"`
+	Sequence       int64  `json:"sequence"`
+	Token          string `json:"token"`
+}
+
+type inspectionResponse struct {
+	Action          string `json:"action"`
+	RedactedContent string `json:"redacted_content,omitempty"`
+}
+
+func (i *httpInspector) Inspect(ctx context.Context, token *TokenMessage) (Action, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, inspectorCallTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(inspectionRequest{
+		ConversationID: token.ConversationID,
+		Sequence:       token.Sequence,
+		Token:          token.Token,
+	})
+	if err != nil {
+		return ActionAllow, "", fmt.Errorf("marshal inspection request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return ActionAllow, "", fmt.Errorf("building inspection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return ActionAllow, "", fmt.Errorf("calling inspection endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ActionAllow, "", fmt.Errorf("inspection endpoint returned status %d", resp.StatusCode)
+	}
+
+	var parsed inspectionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return ActionAllow, "", fmt.Errorf("decoding inspection response: %w", err)
+	}
+
+	action, err := parseAction(parsed.Action)
+	if err != nil {
+		return ActionAllow, "", err
+	}
+	return action, parsed.RedactedContent, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Subscription represents an active subscription on a MessageBus.
+type Subscription interface {
+	Unsubscribe() error
+}
+
+// MessageBus abstracts the pub/sub fan-out layer so SSEHandler can run
+// against NATS or RabbitMQ without branching through its connection
+// handling. Subjects/routing keys use the same "chat.<conversation_id>.tokens"
+// shape regardless of backend.
+type MessageBus interface {
+	Publish(subject string, data []byte) error
+	Subscribe(subject string, handler func(subject string, data []byte)) (Subscription, error)
+	Connected() bool
+	Close()
+}
+
+func newMessageBus(cfg *Config) (MessageBus, error) {
+	switch cfg.BusKind {
+	case "nats":
+		return newNATSBus(cfg)
+	case "rabbitmq":
+		return newRabbitMQBus(cfg)
+	default:
+		return nil, fmt.Errorf("unknown BUS_KIND %q (want nats or rabbitmq)", cfg.BusKind)
+	}
+}
+
+// --- NATS ---
+
+type natsBus struct {
+	nc *nats.Conn
+}
+
+func newNATSBus(cfg *Config) (*natsBus, error) {
+	nc, err := nats.Connect(cfg.BusURL,
+		nats.Name("sse-adapter"),
+		nats.ReconnectWait(2*time.Second),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(nc *nats.Conn, err error) {
+			slog.Warn("NATS disconnected", "error", err)
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to NATS: %w", err)
+	}
+	return &natsBus{nc: nc}, nil
+}
+
+func (b *natsBus) Publish(subject string, data []byte) error {
+	return b.nc.Publish(subject, data)
+}
+
+func (b *natsBus) Subscribe(subject string, handler func(subject string, data []byte)) (Subscription, error) {
+	sub, err := b.nc.Subscribe(subject, func(msg *nats.Msg) {
+		handler(msg.Subject, msg.Data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return natsSubscription{sub: sub}, nil
+}
+
+func (b *natsBus) Connected() bool {
+	return b.nc.Status() == nats.CONNECTED
+}
+
+func (b *natsBus) Close() {
+	b.nc.Close()
+}
+
+type natsSubscription struct {
+	sub *nats.Subscription
+}
+
+func (s natsSubscription) Unsubscribe() error {
+	return s.sub.Unsubscribe()
+}
+
+// --- RabbitMQ ---
+
+// rabbitExchange is the topic exchange every bus participant publishes to
+// and binds queues against, using "chat.<conversation_id>.tokens" routing
+// keys - the same shape as the NATS subjects they replace.
+const rabbitExchange = "chat_tokens"
+
+type rabbitBus struct {
+	conn  *amqp.Connection
+	pubCh *amqp.Channel
+	pubMu sync.Mutex
+}
+
+func newRabbitMQBus(cfg *Config) (*rabbitBus, error) {
+	conn, err := dialRabbitMQ(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("opening RabbitMQ channel: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(rabbitExchange, "topic", true, false, false, false, nil); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, fmt.Errorf("declaring RabbitMQ exchange: %w", err)
+	}
+
+	return &rabbitBus{conn: conn, pubCh: ch}, nil
+}
+
+func dialRabbitMQ(cfg *Config) (*amqp.Connection, error) {
+	if cfg.BusTLSCertFile == "" && cfg.BusTLSCAFile == "" {
+		conn, err := amqp.Dial(cfg.BusURL)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to RabbitMQ: %w", err)
+		}
+		return conn, nil
+	}
+
+	tlsCfg, err := buildBusTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := amqp.DialTLS(cfg.BusURL, tlsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to RabbitMQ over TLS: %w", err)
+	}
+	return conn, nil
+}
+
+func buildBusTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{InsecureSkipVerify: cfg.BusTLSInsecureSkipVerify}
+
+	if cfg.BusTLSCertFile != "" && cfg.BusTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.BusTLSCertFile, cfg.BusTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading bus TLS client cert: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.BusTLSCAFile != "" {
+		caCert, err := os.ReadFile(cfg.BusTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading bus TLS CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.BusTLSCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	return tlsCfg, nil
+}
+
+func (b *rabbitBus) Publish(subject string, data []byte) error {
+	b.pubMu.Lock()
+	defer b.pubMu.Unlock()
+
+	return b.pubCh.Publish(rabbitExchange, subject, false, false, amqp.Publishing{
+		ContentType: "application/json",
+		Body:        data,
+	})
+}
+
+// Subscribe declares an exclusive, auto-deleted queue bound to subject on
+// its own channel, mirroring a NATS subject subscription - one queue per
+// Hub entry (one per conversation, shared by all its SSE connections), torn
+// down when the Hub unsubscribes it.
+func (b *rabbitBus) Subscribe(subject string, handler func(subject string, data []byte)) (Subscription, error) {
+	ch, err := b.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("opening RabbitMQ channel: %w", err)
+	}
+
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("declaring RabbitMQ queue: %w", err)
+	}
+
+	if err := ch.QueueBind(q.Name, subject, rabbitExchange, false, nil); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("binding RabbitMQ queue: %w", err)
+	}
+
+	deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("consuming RabbitMQ queue: %w", err)
+	}
+
+	go func() {
+		for d := range deliveries {
+			handler(d.RoutingKey, d.Body)
+		}
+	}()
+
+	return &rabbitSubscription{channel: ch}, nil
+}
+
+func (b *rabbitBus) Connected() bool {
+	return b.conn != nil && !b.conn.IsClosed()
+}
+
+func (b *rabbitBus) Close() {
+	b.pubCh.Close()
+	b.conn.Close()
+}
+
+type rabbitSubscription struct {
+	channel *amqp.Channel
+}
+
+// Unsubscribe closes the subscription's own channel, which ends its Consume
+// loop and auto-deletes its exclusive queue.
+func (s *rabbitSubscription) Unsubscribe() error {
+	return s.channel.Close()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// connLimiter enforces Config.MaxConnectionsPerIP across the lifetime of
+// SSE connections. A count of 0 means unlimited.
+type connLimiter struct {
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newConnLimiter(max int) *connLimiter {
+	return &connLimiter{max: max, counts: make(map[string]int)}
+}
+
+// Acquire reserves a connection slot for clientIP, returning false if that
+// would exceed max. Every successful Acquire must be matched by a Release.
+func (l *connLimiter) Acquire(clientIP string) bool {
+	if l.max <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts[clientIP] >= l.max {
+		return false
+	}
+	l.counts[clientIP]++
+	return true
+}
+
+func (l *connLimiter) Release(clientIP string) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[clientIP]--
+	if l.counts[clientIP] <= 0 {
+		delete(l.counts, clientIP)
+	}
+}
+
+// tokenBucket is a classic token bucket: capacity tokens, refilled at
+// ratePerSec, lazily topped up on each Allow call rather than with a
+// background ticker.
+type tokenBucket struct {
+	ratePerSec float64
+	capacity   float64
+
+	mu        sync.Mutex
+	tokens    float64
+	lastCheck time.Time
+}
+
+func newTokenBucket(ratePerSec float64, capacity float64) *tokenBucket {
+	return &tokenBucket{
+		ratePerSec: ratePerSec,
+		capacity:   capacity,
+		tokens:     capacity,
+		lastCheck:  time.Now(),
+	}
+}
+
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastCheck).Seconds()
+	b.lastCheck = now
+
+	b.tokens += elapsed * b.ratePerSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// chatLimiterIdleTTL bounds how long a per-IP bucket is kept after its last
+// request, so a stream of distinct source IPs can't grow the map without
+// bound - the same kind of cap connLimiter gets for free by deleting on
+// zero.
+const chatLimiterIdleTTL = 10 * time.Minute
+
+// chatRateLimiter hands out a per-IP tokenBucket, matching Config's
+// ChatRateLimitPerSec/ChatRateLimitBurst. A rate of 0 disables limiting.
+type chatRateLimiter struct {
+	ratePerSec float64
+	burst      float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newChatRateLimiter(ratePerSec float64, burst int) *chatRateLimiter {
+	return &chatRateLimiter{ratePerSec: ratePerSec, burst: float64(burst)}
+}
+
+func (l *chatRateLimiter) Allow(clientIP string) bool {
+	if l.ratePerSec <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*tokenBucket)
+	}
+	l.evictIdleLocked()
+	b, ok := l.buckets[clientIP]
+	if !ok {
+		b = newTokenBucket(l.ratePerSec, l.burst)
+		l.buckets[clientIP] = b
+	}
+	l.mu.Unlock()
+
+	return b.Allow()
+}
+
+// evictIdleLocked drops buckets idle for more than chatLimiterIdleTTL.
+// Called opportunistically from Allow; l.mu must already be held.
+func (l *chatRateLimiter) evictIdleLocked() {
+	cutoff := time.Now().Add(-chatLimiterIdleTTL)
+	for ip, b := range l.buckets {
+		b.mu.Lock()
+		idle := b.lastCheck.Before(cutoff)
+		b.mu.Unlock()
+		if idle {
+			delete(l.buckets, ip)
+		}
+	}
+}
@@ -2,20 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strings"
-	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/nats-io/nats.go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
 )
 
 // Metrics
@@ -40,6 +40,21 @@ var (
 		Help:    "Duration of SSE connections",
 		Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600},
 	})
+
+	cacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sse_conversation_cache_hits_total",
+		Help: "Total number of /stream/:id requests whose conversation was present in the in-process conversation cache.",
+	})
+
+	cacheMissesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sse_conversation_cache_misses_total",
+		Help: "Total number of /stream/:id requests whose conversation was not in the in-process conversation cache.",
+	})
+
+	rejectedConnectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "sse_rejected_connections_total",
+		Help: "Total number of connections/requests rejected before being served, by reason.",
+	}, []string{"reason"})
 )
 
 // TokenMessage represents a token from the LLM
@@ -49,6 +64,7 @@ type TokenMessage struct {
 	Sequence       int64  `json:"sequence"`
 	Done           bool   `json:"done"`
 	Timestamp      int64  `json:"timestamp"`
+	ContentType    string `json:"content_type,omitempty"` // reserved for future multimodal tokens; empty for plain text
 }
 
 // ChatRequest represents an incoming chat request
@@ -59,19 +75,36 @@ type ChatRequest struct {
 
 // SSEHandler handles SSE connections
 type SSEHandler struct {
-	nc     *nats.Conn
-	config *Config
-	
-	// Track active subscriptions for cleanup
-	subscriptions sync.Map
-	connCount     atomic.Int64
+	bus        MessageBus
+	hub        *Hub          // multiplexes one bus subscription per conversation across clients
+	rdb        *redis.Client // optional; enables Redis Stream replay on /stream/:id
+	durableLog DurableLog    // optional; enables replay on /stream/:id when rdb is nil
+	pipeline   *Pipeline     // screens tokens per Config.InspectionMode before/alongside delivery
+	cache      *ConversationCache
+	config     *Config
+
+	connLimiter *connLimiter     // enforces Config.MaxConnectionsPerIP
+	chatLimiter *chatRateLimiter // enforces Config.ChatRateLimitPerSec on /chat
+
+	connCount atomic.Int64
 }
 
-// NewSSEHandler creates a new SSE handler
-func NewSSEHandler(nc *nats.Conn, cfg *Config) *SSEHandler {
+// NewSSEHandler creates a new SSE handler. rdb and durableLog may both be
+// nil, in which case /stream/:id falls back to a plain bus subscription
+// with no replay. When rdb is set it takes priority over durableLog, since
+// Redis Streams already give streamFromRedis gapless tail-and-replay.
+func NewSSEHandler(bus MessageBus, rdb *redis.Client, durableLog DurableLog, cfg *Config) *SSEHandler {
 	return &SSEHandler{
-		nc:     nc,
-		config: cfg,
+		bus:        bus,
+		hub:        NewHub(bus, cfg.WireFormat),
+		rdb:        rdb,
+		durableLog: durableLog,
+		pipeline:   newPipeline(cfg),
+		cache:      NewConversationCache(),
+		config:     cfg,
+
+		connLimiter: newConnLimiter(cfg.MaxConnectionsPerIP),
+		chatLimiter: newChatRateLimiter(cfg.ChatRateLimitPerSec, cfg.ChatRateLimitBurst),
 	}
 }
 
@@ -81,19 +114,30 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	// Extract conversation ID from URL
 	path := strings.TrimPrefix(r.URL.Path, "/stream/")
 	conversationID := strings.TrimSuffix(path, "/")
-	
+
 	if conversationID == "" {
 		http.Error(w, "conversation_id required", http.StatusBadRequest)
 		return
 	}
 
+	clientIP := resolveClientIP(r, h.config.TrustedProxies)
+	if !h.connLimiter.Acquire(clientIP) {
+		rejectedConnectionsTotal.WithLabelValues("per_ip_limit").Inc()
+		slog.Warn("Rejected SSE connection: per-IP limit reached", "client_ip", clientIP, "conversation_id", conversationID)
+		http.Error(w, "too many connections from this client", http.StatusTooManyRequests)
+		return
+	}
+	defer h.connLimiter.Release(clientIP)
+
 	// Check for Last-Event-ID for reconnection handling
 	lastEventID := r.Header.Get("Last-Event-ID")
 	var startSequence int64
 	if lastEventID != "" {
 		fmt.Sscanf(lastEventID, "%d", &startSequence)
-		startSequence++ // Start from next message
 	}
+	// startSequence is the last sequence the client already has; every
+	// replay/filter path below delivers strictly greater than it (resume at
+	// startSequence+1), matching DurableLog.Replay's afterSequence contract.
 
 	// Set SSE headers
 	w.Header().Set("Content-Type", "text/event-stream")
@@ -113,7 +157,7 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	activeConnections.Inc()
 	totalConnections.Inc()
-	
+
 	defer func() {
 		activeConnections.Dec()
 		connectionDuration.Observe(time.Since(startTime).Seconds())
@@ -122,54 +166,50 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	slog.Info("SSE connection started",
 		"conversation_id", conversationID,
 		"conn_id", connID,
+		"client_ip", clientIP,
 		"last_event_id", lastEventID,
 	)
 
 	// Create channel for messages
 	msgChan := make(chan *TokenMessage, 100)
+	lagChan := make(chan [2]int64, 1)
 	doneChan := make(chan struct{})
 
-	// Subscribe to NATS subject for this conversation
-	subject := fmt.Sprintf("chat.%s.tokens", conversationID)
-	
-	sub, err := h.nc.Subscribe(subject, func(msg *nats.Msg) {
-		var token TokenMessage
-		if err := json.Unmarshal(msg.Data, &token); err != nil {
-			slog.Error("Failed to unmarshal token", "error", err)
-			return
-		}
-
-		// Skip messages before our start sequence (for reconnection)
-		if startSequence > 0 && token.Sequence <= startSequence {
-			return
-		}
-
-		select {
-		case msgChan <- &token:
-		case <-doneChan:
+	var hc *hubClient
+	var skipSequence atomic.Int64
+	skipSequence.Store(startSequence)
+
+	if h.rdb != nil {
+		// Redis Streams mode: replay anything buffered since startSequence,
+		// then tail the stream for new tokens. This is what lets a browser's
+		// built-in Last-Event-ID reconnect actually resume instead of
+		// silently skipping whatever arrived while it was disconnected.
+		go h.streamFromRedis(r.Context(), conversationID, startSequence, msgChan, doneChan)
+	} else {
+		// Join the conversation's Hub entry BEFORE draining the durable log,
+		// so nothing published during the drain is missed. The Hub owns the
+		// single bus subscription shared by every client on this
+		// conversation; hc is this connection's own slow-consumer-safe ring
+		// buffer within it. We deliberately don't start forwarding hc.tokens
+		// into msgChan yet - see below, after the durable log replay.
+		var err error
+		hc, err = h.hub.Join(conversationID)
+		if err != nil {
+			slog.Error("Failed to join hub", "error", err, "conversation_id", conversationID)
+			http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
 			return
-		default:
-			slog.Warn("Message channel full, dropping message",
-				"conversation_id", conversationID,
-				"sequence", token.Sequence,
-			)
 		}
-	})
-
-	if err != nil {
-		slog.Error("Failed to subscribe to NATS", "error", err, "subject", subject)
-		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
-		return
 	}
-	
-	h.subscriptions.Store(connID, sub)
+
 	defer func() {
 		close(doneChan)
-		sub.Unsubscribe()
-		h.subscriptions.Delete(connID)
+		if hc != nil {
+			h.hub.Leave(conversationID, hc)
+		}
 		slog.Info("SSE connection closed",
 			"conversation_id", conversationID,
 			"conn_id", connID,
+			"client_ip", clientIP,
 			"duration", time.Since(startTime),
 		)
 	}()
@@ -178,10 +218,100 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, ": connected to %s\n\n", conversationID)
 	flusher.Flush()
 
+	// Durable log replay: only applies to the bus-subscription path above
+	// (Redis Streams mode already replays via streamFromRedis). Drains the
+	// durable log directly to the response writer, ahead of msgChan, then
+	// raises skipSequence so the live subscription doesn't redeliver it.
+	if h.rdb == nil && h.durableLog != nil {
+		replayed, evicted, err := h.durableLog.Replay(r.Context(), conversationID, startSequence)
+		if err != nil {
+			slog.Error("Durable log replay failed", "error", err, "conversation_id", conversationID)
+		}
+
+		if evicted {
+			fmt.Fprintf(w, "event: replay_unavailable\n")
+			fmt.Fprintf(w, "data: {\"conversation_id\":\"%s\",\"requested_sequence\":%d}\n\n", conversationID, startSequence)
+			flusher.Flush()
+		}
+
+		highest := startSequence
+		for i := range replayed {
+			token := &replayed[i]
+			highest = token.Sequence
+
+			// Replay bypasses msgChan, so it bypasses pipeline.Wrap too;
+			// inline-inspect each token here instead so a reconnect can't be
+			// used to read tokens inline mode would otherwise have
+			// Dropped/Redacted.
+			if h.pipeline.InspectOne(r.Context(), token) {
+				data, _ := json.Marshal(token)
+				fmt.Fprintf(w, "event: token\n")
+				fmt.Fprintf(w, "id: %d\n", token.Sequence)
+				fmt.Fprintf(w, "data: %s\n\n", data)
+				flusher.Flush()
+
+				messagesDelivered.Inc()
+			}
+
+			if token.Done {
+				slog.Info("Stream completed from durable log replay",
+					"conversation_id", conversationID,
+					"final_sequence", token.Sequence,
+				)
+				return
+			}
+		}
+		skipSequence.Store(highest)
+	}
+
+	// Only now start forwarding this client's hub broadcasts into msgChan -
+	// after skipSequence reflects everything the durable log replay above
+	// already delivered. Starting this any earlier (e.g. right after Join)
+	// would let live tokens in the replay's range slip into msgChan while
+	// skipSequence still held its pre-replay value, duplicating what the
+	// replay just wrote to w. hc.tokens is its own bounded buffer, so
+	// anything published while we were draining the durable log sits there
+	// (or triggers the usual slow-consumer drop+lag) until we start reading
+	// it here; nothing is lost by waiting unless more than hubClientBuffer
+	// tokens arrive meanwhile, in which case the oldest are dropped and
+	// reported as an "event: lag" frame.
+	if hc != nil {
+		go func() {
+			for {
+				select {
+				case <-doneChan:
+					return
+				case token := <-hc.tokens:
+					// Skip messages already delivered by the durable log
+					// replay above.
+					if skip := skipSequence.Load(); skip > 0 && token.Sequence <= skip {
+						continue
+					}
+					select {
+					case msgChan <- token:
+					case <-doneChan:
+						return
+					}
+				case lagRange := <-hc.lag:
+					select {
+					case lagChan <- lagRange:
+					case <-doneChan:
+						return
+					default:
+					}
+				}
+			}
+		}()
+	}
+
 	// Keep-alive ticker
 	keepAliveTicker := time.NewTicker(15 * time.Second)
 	defer keepAliveTicker.Stop()
 
+	// Wrap msgChan with the configured inspection mode (inline/async/hybrid,
+	// or a pass-through when inspection is disabled).
+	pipelineOut := h.pipeline.Wrap(r.Context(), msgChan, doneChan)
+
 	// Main event loop
 	for {
 		select {
@@ -189,23 +319,15 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 			// Client disconnected
 			return
 
-		case token := <-msgChan:
-			// Security inspection is disabled for now.
-			// See README.md and docs/security-inspection-patterns.md for available modes:
-			//   - inline: Block delivery until inspector approves (adds latency)
-			//   - async: Deliver immediately, inspect in parallel, alert if flagged
-			//   - hybrid: Buffer for 100-200ms, inspect during buffer window
-			//
-			// To implement, uncomment and add inspection logic:
-			// if h.config.InspectionMode == "inline" {
-			//     result, err := callInspector(h.config.InspectionEndpoint, token)
-			//     if err != nil || result.Action == ActionDrop {
-			//         continue // Skip this token
-			//     }
-			//     if result.Action == ActionRedact {
-			//         token.Token = result.RedactedContent
-			//     }
-			// }
+		case out := <-pipelineOut:
+			if out.alert {
+				fmt.Fprintf(w, "event: alert\n")
+				fmt.Fprintf(w, "data: {\"conversation_id\":\"%s\",\"sequence\":%d}\n\n", conversationID, out.alertSequence)
+				flusher.Flush()
+				continue
+			}
+
+			token := out.token
 
 			// Format and send SSE event
 			data, _ := json.Marshal(token)
@@ -213,7 +335,7 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "id: %d\n", token.Sequence)
 			fmt.Fprintf(w, "data: %s\n\n", data)
 			flusher.Flush()
-			
+
 			messagesDelivered.Inc()
 
 			// Close connection if this is the last token
@@ -225,6 +347,13 @@ func (h *SSEHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+		case lagRange := <-lagChan:
+			// A slow consumer's ring buffer overflowed in the Hub; tell the
+			// client which sequence range it lost so it can notice the gap.
+			fmt.Fprintf(w, "event: lag\n")
+			fmt.Fprintf(w, "data: {\"conversation_id\":\"%s\",\"from_sequence\":%d,\"to_sequence\":%d}\n\n", conversationID, lagRange[0], lagRange[1])
+			flusher.Flush()
+
 		case <-keepAliveTicker.C:
 			// Send keep-alive comment
 			fmt.Fprintf(w, ": keep-alive\n\n")
@@ -257,6 +386,23 @@ func (h *SSEHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	clientIP := resolveClientIP(r, h.config.TrustedProxies)
+
+	if !h.chatLimiter.Allow(clientIP) {
+		rejectedConnectionsTotal.WithLabelValues("rate_limited").Inc()
+		slog.Warn("Rejected /chat request: rate limit exceeded", "client_ip", clientIP)
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	if !h.connLimiter.Acquire(clientIP) {
+		rejectedConnectionsTotal.WithLabelValues("per_ip_limit").Inc()
+		slog.Warn("Rejected /chat connection: per-IP limit reached", "client_ip", clientIP)
+		http.Error(w, "too many connections from this client", http.StatusTooManyRequests)
+		return
+	}
+	defer h.connLimiter.Release(clientIP)
+
 	// Parse request body
 	var chatReq ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&chatReq); err != nil {
@@ -302,50 +448,54 @@ func (h *SSEHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Chat request received",
 		"conversation_id", conversationID,
 		"conn_id", connID,
+		"client_ip", clientIP,
 		"message_length", len(chatReq.Message),
 	)
 
 	// Create channel for messages
 	msgChan := make(chan *TokenMessage, 100)
+	lagChan := make(chan [2]int64, 1)
 	doneChan := make(chan struct{})
 	errChan := make(chan error, 1)
 
-	// Subscribe to NATS subject for this conversation BEFORE forwarding
-	subject := fmt.Sprintf("chat.%s.tokens", conversationID)
-
-	sub, err := h.nc.Subscribe(subject, func(msg *nats.Msg) {
-		var token TokenMessage
-		if err := json.Unmarshal(msg.Data, &token); err != nil {
-			slog.Error("Failed to unmarshal token", "error", err)
-			return
-		}
-
-		select {
-		case msgChan <- &token:
-		case <-doneChan:
-			return
-		default:
-			slog.Warn("Message channel full, dropping message",
-				"conversation_id", conversationID,
-				"sequence", token.Sequence,
-			)
-		}
-	})
-
+	// Join the conversation's Hub entry BEFORE forwarding to the origin
+	// proxy, so nothing published while the request is in flight is missed.
+	hc, err := h.hub.Join(conversationID)
 	if err != nil {
-		slog.Error("Failed to subscribe to NATS", "error", err, "subject", subject)
+		slog.Error("Failed to join hub", "error", err, "conversation_id", conversationID)
 		http.Error(w, "Failed to subscribe", http.StatusInternalServerError)
 		return
 	}
 
-	h.subscriptions.Store(connID, sub)
+	go func() {
+		for {
+			select {
+			case <-doneChan:
+				return
+			case token := <-hc.tokens:
+				select {
+				case msgChan <- token:
+				case <-doneChan:
+					return
+				}
+			case lagRange := <-hc.lag:
+				select {
+				case lagChan <- lagRange:
+				case <-doneChan:
+					return
+				default:
+				}
+			}
+		}
+	}()
+
 	defer func() {
 		close(doneChan)
-		sub.Unsubscribe()
-		h.subscriptions.Delete(connID)
+		h.hub.Leave(conversationID, hc)
 		slog.Info("Chat connection closed",
 			"conversation_id", conversationID,
 			"conn_id", connID,
+			"client_ip", clientIP,
 			"duration", time.Since(startTime),
 		)
 	}()
@@ -396,6 +546,10 @@ func (h *SSEHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 	defer firstTokenTimeout.Stop()
 	receivedFirstToken := false
 
+	// Wrap msgChan with the configured inspection mode (inline/async/hybrid,
+	// or a pass-through when inspection is disabled).
+	pipelineOut := h.pipeline.Wrap(r.Context(), msgChan, doneChan)
+
 	// Main event loop
 	for {
 		select {
@@ -419,7 +573,15 @@ func (h *SSEHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-		case token := <-msgChan:
+		case out := <-pipelineOut:
+			if out.alert {
+				fmt.Fprintf(w, "event: alert\n")
+				fmt.Fprintf(w, "data: {\"conversation_id\":\"%s\",\"sequence\":%d}\n\n", conversationID, out.alertSequence)
+				flusher.Flush()
+				continue
+			}
+
+			token := out.token
 			receivedFirstToken = true
 			firstTokenTimeout.Stop()
 
@@ -441,6 +603,13 @@ func (h *SSEHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+		case lagRange := <-lagChan:
+			// A slow consumer's ring buffer overflowed in the Hub; tell the
+			// client which sequence range it lost so it can notice the gap.
+			fmt.Fprintf(w, "event: lag\n")
+			fmt.Fprintf(w, "data: {\"conversation_id\":\"%s\",\"from_sequence\":%d,\"to_sequence\":%d}\n\n", conversationID, lagRange[0], lagRange[1])
+			flusher.Flush()
+
 		case <-keepAliveTicker.C:
 			// Send keep-alive comment
 			fmt.Fprintf(w, ": keep-alive\n\n")
@@ -448,3 +617,142 @@ func (h *SSEHandler) HandleChat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// redisStreamKey returns the Redis Stream key the proxy appends tokens to
+// for a conversation. Must match streamKey()/streamKeyName() in the
+// llm-stream-proxy service, including the cluster hash tag: the adapter is
+// the one subscribing to this stream, so if RedisClusterMode disagrees with
+// the proxy's REDIS_MODE, replay reads a key nothing was ever written to.
+func (h *SSEHandler) redisStreamKey(conversationID string) string {
+	if h.config.RedisClusterMode {
+		return fmt.Sprintf("llm:stream:{%s}", conversationID)
+	}
+	return "llm:stream:" + conversationID
+}
+
+// streamFromRedis replays any tokens with sequence > startSequence already
+// buffered in the conversation's capped Redis Stream, then blocks for new
+// ones, feeding msgChan until doneChan is closed. It runs in its own
+// goroutine for the lifetime of an SSE connection.
+//
+// Before touching Redis, it checks the adapter's local ConversationCache: if
+// another connection already read this conversation recently, the cached
+// tokens are replayed straight from RAM and tailing resumes from the cached
+// Redis Stream ID, skipping the XRANGE history read entirely.
+func (h *SSEHandler) streamFromRedis(ctx context.Context, conversationID string, startSequence int64, msgChan chan<- *TokenMessage, doneChan <-chan struct{}) {
+	key := h.redisStreamKey(conversationID)
+
+	lastID := "0"
+	effectiveStart := startSequence
+	skipHistoryRead := false
+
+	if cached, lastCachedID, hit, covered := h.cache.Replay(conversationID, startSequence); hit {
+		cacheHitsTotal.Inc()
+		// Only trust the cache at all when its ring actually reaches back to
+		// startSequence. A ring that has rotated past it still holds a
+		// cached *suffix*, but delivering just that suffix (and advancing
+		// effectiveStart past the gap) would make the XRANGE below filter
+		// out everything between startSequence and the oldest cached
+		// sequence - the gap has to come from XRANGE, not the cache.
+		if covered {
+			for _, token := range cached {
+				select {
+				case msgChan <- token:
+				case <-doneChan:
+					return
+				}
+				effectiveStart = token.Sequence
+				if token.Done {
+					return // conversation already finished; nothing left worth reading
+				}
+			}
+			if lastCachedID != "" {
+				lastID = lastCachedID
+				skipHistoryRead = true
+			}
+		}
+	} else {
+		cacheMissesTotal.Inc()
+	}
+
+	if !skipHistoryRead {
+		entries, err := h.rdb.XRange(ctx, key, "-", "+").Result()
+		if err != nil {
+			slog.Error("Failed to read Redis stream for replay", "error", err, "stream", key)
+		}
+		for _, entry := range entries {
+			lastID = entry.ID
+			token, ok := decodeStreamEntry(entry)
+			if !ok {
+				continue
+			}
+			h.cache.Append(conversationID, token, entry.ID)
+			if token.Sequence <= effectiveStart {
+				continue
+			}
+			select {
+			case msgChan <- token:
+			case <-doneChan:
+				return
+			}
+		}
+	}
+
+	// Tail the stream for tokens appended after replay, blocking between reads.
+	for {
+		select {
+		case <-doneChan:
+			return
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		res, err := h.rdb.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{key, lastID},
+			Block:   5 * time.Second,
+			Count:   100,
+		}).Result()
+		if err != nil {
+			if err == redis.Nil {
+				continue // block timed out with nothing new; keep tailing
+			}
+			slog.Error("Redis XREAD failed", "error", err, "stream", key)
+			return
+		}
+
+		for _, stream := range res {
+			for _, entry := range stream.Messages {
+				lastID = entry.ID
+				token, ok := decodeStreamEntry(entry)
+				if !ok {
+					continue
+				}
+				h.cache.Append(conversationID, token, entry.ID)
+				select {
+				case msgChan <- token:
+				case <-doneChan:
+					return
+				}
+				if token.Done {
+					return
+				}
+			}
+		}
+	}
+}
+
+// decodeStreamEntry unmarshals the JSON-encoded TokenMessage stored under
+// the "data" field of a Redis Stream entry written by streamKey()/XAdd.
+func decodeStreamEntry(entry redis.XMessage) (*TokenMessage, bool) {
+	raw, ok := entry.Values["data"].(string)
+	if !ok {
+		return nil, false
+	}
+	var token TokenMessage
+	if err := json.Unmarshal([]byte(raw), &token); err != nil {
+		slog.Error("Failed to unmarshal stream entry", "error", err, "id", entry.ID)
+		return nil, false
+	}
+	return &token, true
+}
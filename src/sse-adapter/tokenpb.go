@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeToken parses a bus payload into a TokenMessage, in the format
+// selected by cfg.WireFormat ("json" or "protobuf"). Field numbers match
+// token.proto.
+func decodeToken(data []byte, format string) (TokenMessage, error) {
+	switch format {
+	case "protobuf":
+		return unmarshalTokenProto(data)
+	case "json", "":
+		var token TokenMessage
+		err := json.Unmarshal(data, &token)
+		return token, err
+	default:
+		return TokenMessage{}, fmt.Errorf("unknown wire format %q (want json or protobuf)", format)
+	}
+}
+
+// unmarshalTokenProto hand-decodes the protobuf wire format produced by
+// marshalTokenProto in the redis-nats-bridge service (no protoc-gen-go step
+// on this side either, for the same reason).
+func unmarshalTokenProto(data []byte) (TokenMessage, error) {
+	var token TokenMessage
+
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return token, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch num {
+		case 1:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			token.ConversationID = v
+			data = data[n:]
+		case 2:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			token.Token = string(v)
+			data = data[n:]
+		case 3:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			token.Sequence = int64(v)
+			data = data[n:]
+		case 4:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			token.Done = v != 0
+			data = data[n:]
+		case 5:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			token.Timestamp = int64(v)
+			data = data[n:]
+		case 6:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			token.ContentType = v
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return token, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+
+	return token, nil
+}